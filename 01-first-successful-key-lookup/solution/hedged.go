@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Clock abstracts waiting, so hedging can be tested without sleeping.
+type Clock interface {
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// HedgePolicy configures GetHedged.
+type HedgePolicy struct {
+	// Delay before the next address is tried while no response has arrived.
+	Delay time.Duration
+	// Clock used to wait for Delay. If nil, real time is used.
+	Clock Clock
+}
+
+// Call `Getter.Get()` for addresses one by one.
+// The next request starts after policy.Delay, or right away if a request failed.
+// Returns the first successful response and cancels the rest.
+// If all requests fail, returns a *LookupError with every failure.
+// If ctx is done first, addresses without a response fail with ctx.Err().
+func GetHedged(ctx context.Context, getter Getter, addresses []string, key string, policy HedgePolicy) (string, error) {
+	if len(addresses) == 0 {
+		return "", ErrNoAddresses
+	}
+
+	clock := policy.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type failure struct {
+		idx int
+		err error
+	}
+
+	// Same as in Get, channels are buffered, so losers never block
+	resCh, errCh := make(chan string, 1), make(chan failure, len(addresses))
+
+	var next int
+	launch := func() {
+		i, address := next, addresses[next]
+		next++
+		go func() {
+			if val, err := getter.Get(ctx, address, key); err != nil {
+				errCh <- failure{idx: i, err: err}
+			} else {
+				select {
+				case resCh <- val:
+				default:
+				}
+			}
+		}()
+	}
+
+	launch()
+	errs := make([]*AddressError, len(addresses))
+	var errCount int
+	for {
+		// Timer is restarted after every launch.
+		// Nil channel blocks forever, so once every address is launched we just wait
+		var timer <-chan time.Time
+		if next < len(addresses) {
+			timer = clock.After(policy.Delay)
+		}
+
+		select {
+		case <-timer:
+			launch()
+		case f := <-errCh:
+			errs[f.idx] = &AddressError{Address: addresses[f.idx], Err: f.err}
+			errCount++
+			if errCount == len(addresses) {
+				return "", &LookupError{Errors: errs}
+			}
+			// Don't wait for the delay, there is one request less in flight
+			if next < len(addresses) {
+				launch()
+			}
+		case val := <-resCh:
+			return val, nil
+		case <-ctx.Done():
+			// Same as in Get, addresses without a response fail with the context error
+			for i, address := range addresses {
+				if errs[i] == nil {
+					errs[i] = &AddressError{Address: address, Err: ctx.Err()}
+				}
+			}
+			return "", &LookupError{Errors: errs}
+		}
+	}
+}
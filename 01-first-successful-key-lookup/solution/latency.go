@@ -0,0 +1,150 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+const DefaultAlpha = 0.2
+
+// Failures are usually fast, so they are charged as if they were slow.
+// Otherwise an address that always fails would look like the best one.
+const errorPenalty = float64(time.Second)
+
+type addressStats struct {
+	// Exponentially weighted moving averages
+	latency   float64 // nanoseconds
+	errorRate float64 // from 0 to 1
+}
+
+// score is lower for better addresses
+func (s *addressStats) score() float64 {
+	return s.latency + s.errorRate*errorPenalty
+}
+
+// LatencyTracker wraps Getter and keeps moving latency
+// and error rate estimates for every address.
+type LatencyTracker struct {
+	getter Getter
+	alpha  float64
+	stats  map[string]*addressStats
+	mu     sync.Mutex
+}
+
+// alpha is the weight of the newest sample, from 0 to 1.
+// If alpha is out of range, DefaultAlpha is used.
+func NewLatencyTracker(getter Getter, alpha float64) *LatencyTracker {
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultAlpha
+	}
+	return &LatencyTracker{getter: getter, alpha: alpha, stats: make(map[string]*addressStats)}
+}
+
+// Get calls the wrapped Getter and records the outcome.
+// Requests cancelled by the caller are not recorded,
+// because they tell nothing about the address.
+func (t *LatencyTracker) Get(ctx context.Context, address, key string) (string, error) {
+	start := time.Now()
+	val, err := t.getter.Get(ctx, address, key)
+	if err != nil && ctx.Err() != nil {
+		return val, err
+	}
+	t.record(address, time.Since(start), err != nil)
+	return val, err
+}
+
+func (t *LatencyTracker) record(address string, latency time.Duration, failed bool) {
+	var fail float64
+	if failed {
+		fail = 1
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stats[address]
+	if s == nil {
+		t.stats[address] = &addressStats{latency: float64(latency), errorRate: fail}
+		return
+	}
+	s.latency = t.alpha*float64(latency) + (1-t.alpha)*s.latency
+	s.errorRate = t.alpha*fail + (1-t.alpha)*s.errorRate
+}
+
+// recordAtLeast records a request that was cancelled after latency
+// without a response. The address is at least that slow, so the estimate
+// only grows, but the request didn't fail.
+func (t *LatencyTracker) recordAtLeast(address string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stats[address]
+	switch {
+	case s == nil:
+		t.stats[address] = &addressStats{latency: float64(latency)}
+	case float64(latency) > s.latency:
+		s.latency = t.alpha*float64(latency) + (1-t.alpha)*s.latency
+	}
+}
+
+// Stats returns the current estimates for address.
+// ok is false if nothing was recorded for it yet.
+func (t *LatencyTracker) Stats(address string) (latency time.Duration, errorRate float64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stats[address]
+	if s == nil {
+		return 0, 0, false
+	}
+	return time.Duration(s.latency), s.errorRate, true
+}
+
+// Order returns a copy of addresses sorted from the fastest and healthiest.
+// Addresses without stats go first, so every address gets probed.
+// Ties keep the original order.
+func (t *LatencyTracker) Order(addresses []string) []string {
+	t.mu.Lock()
+	scores := make(map[string]float64, len(addresses))
+	for _, address := range addresses {
+		if s := t.stats[address]; s != nil {
+			scores[address] = s.score()
+		}
+	}
+	t.mu.Unlock()
+
+	ordered := slices.Clone(addresses)
+	slices.SortStableFunc(ordered, func(a, b string) int {
+		return cmp.Compare(scores[a], scores[b])
+	})
+	return ordered
+}
+
+// Lookup tries addresses from the best to the worst with GetHedged.
+// Requests cancelled because another address answered first are recorded
+// as a lower bound of latency, so an address that keeps losing moves back.
+func (t *LatencyTracker) Lookup(ctx context.Context, addresses []string, key string, policy HedgePolicy) (string, error) {
+	return GetHedged(ctx, lookupGetter{tracker: t, caller: ctx}, t.Order(addresses), key, policy)
+}
+
+// lookupGetter is LatencyTracker.Get that tells requests cancelled
+// by the caller of Lookup from losers cancelled by GetHedged.
+type lookupGetter struct {
+	tracker *LatencyTracker
+	caller  context.Context
+}
+
+func (g lookupGetter) Get(ctx context.Context, address, key string) (string, error) {
+	start := time.Now()
+	val, err := g.tracker.getter.Get(ctx, address, key)
+	switch {
+	case err == nil || ctx.Err() == nil:
+		g.tracker.record(address, time.Since(start), err != nil)
+	case g.caller.Err() == nil:
+		g.tracker.recordAtLeast(address, time.Since(start))
+	}
+	return val, err
+}
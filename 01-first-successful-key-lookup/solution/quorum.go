@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrInvalidQuorum = errors.New("quorum must be between 1 and the number of addresses")
+	ErrNoQuorum      = errors.New("quorum not reached")
+)
+
+// QuorumResult describes the outcome of a quorum read.
+type QuorumResult struct {
+	// Value agreed on by the quorum. Empty if quorum was not reached.
+	Value string
+	// Agreed lists addresses that returned Value.
+	Agreed []string
+	// Disagreed maps every other address that answered to the value it returned.
+	Disagreed map[string]string
+}
+
+// Call `Getter.Get()` for each address in parallel.
+// Returns as soon as r addresses responded with the same value.
+// Remaining requests are cancelled once quorum is reached or becomes impossible.
+func GetQuorum(ctx context.Context, getter Getter, addresses []string, key string, r int) (QuorumResult, error) {
+	if r < 1 || r > len(addresses) {
+		return QuorumResult{}, ErrInvalidQuorum
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type response struct {
+		address string
+		value   string
+		err     error
+	}
+
+	// Buffered for every address, so goroutines that answer
+	// after we have returned don't get stuck forever
+	respCh := make(chan response, len(addresses))
+
+	for _, address := range addresses {
+		go func() {
+			val, err := getter.Get(ctx, address, key)
+			respCh <- response{address: address, value: val, err: err}
+		}()
+	}
+
+	// votes maps every returned value to the addresses that returned it
+	votes := make(map[string][]string)
+	var best int
+	for remaining := len(addresses); ; {
+		select {
+		case resp := <-respCh:
+			remaining--
+			if resp.err == nil {
+				votes[resp.value] = append(votes[resp.value], resp.address)
+				n := len(votes[resp.value])
+				if n == r {
+					return newQuorumResult(votes, resp.value, true), nil
+				}
+				best = max(best, n)
+			}
+			// Even if every pending request agrees with the most popular value
+			// we won't get r votes, so there is no point to wait
+			if best+remaining < r {
+				return newQuorumResult(votes, "", false), ErrNoQuorum
+			}
+		case <-ctx.Done():
+			return newQuorumResult(votes, "", false), ctx.Err()
+		}
+	}
+}
+
+func newQuorumResult(votes map[string][]string, value string, reached bool) QuorumResult {
+	res := QuorumResult{Disagreed: make(map[string]string)}
+	if reached {
+		res.Value, res.Agreed = value, votes[value]
+	}
+	for v, addrs := range votes {
+		if reached && v == value {
+			continue
+		}
+		for _, addr := range addrs {
+			res.Disagreed[addr] = v
+		}
+	}
+	return res
+}
@@ -2,30 +2,98 @@ package main
 
 import (
 	"context"
+	"errors"
+	"strings"
 )
 
-type Getter interface {
-	Get(ctx context.Context, address, key string) (string, error)
+// TypedGetter retrieves a value of type V by a key of type K.
+type TypedGetter[K, V any] interface {
+	Get(ctx context.Context, address string, key K) (V, error)
+}
+
+// Getter is TypedGetter for string keys and values.
+type Getter = TypedGetter[string, string]
+
+var ErrNoAddresses = errors.New("no addresses to look up")
+
+// AddressError is an error returned by `Getter.Get()` for a single address.
+type AddressError struct {
+	Address string
+	Err     error
+}
+
+func (e *AddressError) Error() string {
+	return e.Address + ": " + e.Err.Error()
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// LookupError is returned when every address failed.
+// Errors are listed in the same order as addresses.
+type LookupError struct {
+	Errors []*AddressError
+}
+
+func (e *LookupError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "all lookups failed: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap allows errors.Is and errors.As to match an error of any address.
+func (e *LookupError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// All reports whether every address failed with an error matching target.
+func (e *LookupError) All(target error) bool {
+	for _, err := range e.Errors {
+		if !errors.Is(err, target) {
+			return false
+		}
+	}
+	return len(e.Errors) > 0
 }
 
 // Call `Getter.Get()` for each address in parallel.
 // Returns the first successful response.
-// If all requests fail, returns an error.
+// If all requests fail, returns a *LookupError with every failure.
+// If ctx is done first, addresses without a response fail with ctx.Err().
+// If there are no addresses, returns ErrNoAddresses.
 func Get(ctx context.Context, getter Getter, addresses []string, key string) (string, error) {
+	return GetTyped(ctx, getter, addresses, key)
+}
+
+// GetTyped is Get for any key and value types.
+func GetTyped[K, V any](ctx context.Context, getter TypedGetter[K, V], addresses []string, key K) (V, error) {
+	var zero V
 	if len(addresses) == 0 {
-		return "", nil
+		return zero, ErrNoAddresses
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	type failure struct {
+		idx int
+		err error
+	}
+
 	// Channels MUST be buffered, in other case there is a goroutine leakage
-	resCh, errCh := make(chan string, 1), make(chan error, len(addresses))
+	resCh, errCh := make(chan V, 1), make(chan failure, len(addresses))
 
-	for _, address := range addresses {
+	for i, address := range addresses {
 		go func() {
 			if val, err := getter.Get(ctx, address, key); err != nil {
-				errCh <- err
+				errCh <- failure{idx: i, err: err}
 			} else {
 				// There is a potential goroutine leak, if channel was unbuffered.
 				// If the result is not first, we WILL NOT read this channel
@@ -38,20 +106,29 @@ func Get(ctx context.Context, getter Getter, addresses []string, key string) (st
 		}()
 	}
 
+	errs := make([]*AddressError, len(addresses))
 	var errCount int
 	for {
 		select {
-		case err := <-errCh:
+		case f := <-errCh:
 			// If error count is equal to addresses count
 			// it means that no goroutine left and we can return an error
+			errs[f.idx] = &AddressError{Address: addresses[f.idx], Err: f.err}
 			errCount++
 			if errCount == len(addresses) {
-				return "", err
+				return zero, &LookupError{Errors: errs}
 			}
 		case val := <-resCh:
 			return val, nil
 		case <-ctx.Done():
-			return "", context.Canceled
+			// Addresses that didn't answer failed with the context error,
+			// so the caller can tell a deadline from other failures
+			for i, address := range addresses {
+				if errs[i] == nil {
+					errs[i] = &AddressError{Address: address, Err: ctx.Err()}
+				}
+			}
+			return zero, &LookupError{Errors: errs}
 		}
 	}
 }
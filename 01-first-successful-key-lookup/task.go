@@ -2,15 +2,167 @@ package main
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
 )
 
-type Getter interface {
-	Get(ctx context.Context, address, key string) (string, error)
+// TypedGetter retrieves a value of type V by a key of type K.
+type TypedGetter[K, V any] interface {
+	Get(ctx context.Context, address string, key K) (V, error)
+}
+
+// Getter is TypedGetter for string keys and values.
+type Getter = TypedGetter[string, string]
+
+var ErrNoAddresses = errors.New("no addresses to look up")
+
+// AddressError is an error returned by `Getter.Get()` for a single address.
+type AddressError struct {
+	Address string
+	Err     error
+}
+
+func (e *AddressError) Error() string {
+	return e.Address + ": " + e.Err.Error()
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// LookupError is returned when every address failed.
+// Errors are listed in the same order as addresses.
+type LookupError struct {
+	Errors []*AddressError
+}
+
+func (e *LookupError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "all lookups failed: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap allows errors.Is and errors.As to match an error of any address.
+func (e *LookupError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// All reports whether every address failed with an error matching target.
+func (e *LookupError) All(target error) bool {
+	for _, err := range e.Errors {
+		if !errors.Is(err, target) {
+			return false
+		}
+	}
+	return len(e.Errors) > 0
 }
 
 // Call `Getter.Get()` for each address in parallel.
 // Returns the first successful response.
-// If all requests fail, returns an error.
+// If all requests fail, returns a *LookupError with every failure.
+// If ctx is done first, addresses without a response fail with ctx.Err().
+// If there are no addresses, returns ErrNoAddresses.
 func Get(ctx context.Context, getter Getter, addresses []string, key string) (string, error) {
 	return "", nil
 }
+
+// GetTyped is Get for any key and value types.
+func GetTyped[K, V any](ctx context.Context, getter TypedGetter[K, V], addresses []string, key K) (V, error) {
+	var zero V
+	return zero, nil
+}
+
+var (
+	ErrInvalidQuorum = errors.New("quorum must be between 1 and the number of addresses")
+	ErrNoQuorum      = errors.New("quorum not reached")
+)
+
+// QuorumResult describes the outcome of a quorum read.
+type QuorumResult struct {
+	// Value agreed on by the quorum. Empty if quorum was not reached.
+	Value string
+	// Agreed lists addresses that returned Value.
+	Agreed []string
+	// Disagreed maps every other address that answered to the value it returned.
+	Disagreed map[string]string
+}
+
+// Call `Getter.Get()` for each address in parallel.
+// Returns as soon as r addresses responded with the same value.
+// Remaining requests are cancelled once quorum is reached or becomes impossible.
+func GetQuorum(ctx context.Context, getter Getter, addresses []string, key string, r int) (QuorumResult, error) {
+	return QuorumResult{}, nil
+}
+
+// Clock abstracts waiting, so hedging can be tested without sleeping.
+type Clock interface {
+	After(d time.Duration) <-chan time.Time
+}
+
+// HedgePolicy configures GetHedged.
+type HedgePolicy struct {
+	// Delay before the next address is tried while no response has arrived.
+	Delay time.Duration
+	// Clock used to wait for Delay. If nil, real time is used.
+	Clock Clock
+}
+
+// Call `Getter.Get()` for addresses one by one.
+// The next request starts after policy.Delay, or right away if a request failed.
+// Returns the first successful response and cancels the rest.
+// If all requests fail, returns a *LookupError with every failure.
+// If ctx is done first, addresses without a response fail with ctx.Err().
+func GetHedged(ctx context.Context, getter Getter, addresses []string, key string, policy HedgePolicy) (string, error) {
+	return "", nil
+}
+
+const DefaultAlpha = 0.2
+
+// LatencyTracker wraps Getter and keeps moving latency
+// and error rate estimates for every address.
+type LatencyTracker struct {
+	getter Getter
+	alpha  float64
+	mu     sync.Mutex
+	// You can add new fields if needed
+}
+
+// alpha is the weight of the newest sample, from 0 to 1.
+// If alpha is out of range, DefaultAlpha is used.
+func NewLatencyTracker(getter Getter, alpha float64) *LatencyTracker {
+	return &LatencyTracker{getter: getter, alpha: alpha}
+}
+
+// Get calls the wrapped Getter and records the outcome.
+// Requests cancelled by the caller are not recorded,
+// because they tell nothing about the address.
+func (t *LatencyTracker) Get(ctx context.Context, address, key string) (string, error) {
+	// TODO: Implement. Right now it doesn't record anything
+	return t.getter.Get(ctx, address, key)
+}
+
+// Stats returns the current estimates for address.
+// ok is false if nothing was recorded for it yet.
+func (t *LatencyTracker) Stats(address string) (latency time.Duration, errorRate float64, ok bool) {
+	return 0, 0, false
+}
+
+// Order returns a copy of addresses sorted from the fastest and healthiest.
+// Addresses without stats go first, so every address gets probed.
+// Ties keep the original order.
+func (t *LatencyTracker) Order(addresses []string) []string {
+	return addresses
+}
+
+// Lookup tries addresses from the best to the worst with GetHedged.
+func (t *LatencyTracker) Lookup(ctx context.Context, addresses []string, key string, policy HedgePolicy) (string, error) {
+	return "", nil
+}
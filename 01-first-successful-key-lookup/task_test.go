@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"errors"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 )
@@ -128,7 +131,7 @@ func TestGet(t *testing.T) {
 			key:       "key1",
 			ttl:       50 * time.Millisecond,
 			wantValue: "",
-			wantErr:   false,
+			wantErr:   true,
 		},
 	}
 
@@ -158,3 +161,617 @@ func TestGet(t *testing.T) {
 		})
 	}
 }
+
+type user struct {
+	ID   int
+	Name string
+}
+
+// userGetter implements TypedGetter[int, user]
+type userGetter map[string]map[int]user
+
+func (g userGetter) Get(ctx context.Context, address string, key int) (user, error) {
+	if u, ok := g[address][key]; ok {
+		return u, nil
+	}
+	return user{}, errKeyNotFound
+}
+
+func TestGetTyped(t *testing.T) {
+	getter := userGetter{
+		"addr1": {},
+		"addr2": {1: {ID: 1, Name: "gopher"}},
+	}
+
+	got, err := GetTyped[int, user](context.Background(), getter, []string{"addr1", "addr2"}, 1)
+	if err != nil || got != (user{ID: 1, Name: "gopher"}) {
+		t.Errorf("GetTyped() = %v, %v, want gopher", got, err)
+	}
+
+	got, err = GetTyped[int, user](context.Background(), getter, []string{"addr1", "addr2"}, 2)
+	if !errors.Is(err, errKeyNotFound) || got != (user{}) {
+		t.Errorf("GetTyped() = %v, %v, want zero value and %v", got, err, errKeyNotFound)
+	}
+
+	_, err = GetTyped[int, user](context.Background(), getter, nil, 1)
+	if !errors.Is(err, ErrNoAddresses) {
+		t.Errorf("GetTyped() error = %v, want %v", err, ErrNoAddresses)
+	}
+}
+
+var (
+	errTimeout     = errors.New("timeout")
+	errKeyNotFound = errors.New("key not found")
+)
+
+func TestGetErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		responses   map[string]map[string]Response
+		addresses   []string
+		wantErr     error
+		wantAll     error
+		wantFailed  []string
+		wantNotFail error
+	}{
+		{
+			name:      "empty address list",
+			responses: map[string]map[string]Response{},
+			addresses: []string{},
+			wantErr:   ErrNoAddresses,
+		},
+		{
+			name: "all nodes timed out",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Error: errTimeout}},
+				"addr2": {"key1": {Error: errTimeout}},
+			},
+			addresses:   []string{"addr1", "addr2"},
+			wantErr:     errTimeout,
+			wantAll:     errTimeout,
+			wantFailed:  []string{"addr1", "addr2"},
+			wantNotFail: errKeyNotFound,
+		},
+		{
+			name: "key missing on every node",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Error: errKeyNotFound}},
+				"addr2": {"key1": {Error: errKeyNotFound, Delay: 10 * time.Millisecond}},
+				"addr3": {"key1": {Error: errKeyNotFound}},
+			},
+			addresses:   []string{"addr1", "addr2", "addr3"},
+			wantErr:     errKeyNotFound,
+			wantAll:     errKeyNotFound,
+			wantFailed:  []string{"addr1", "addr2", "addr3"},
+			wantNotFail: errTimeout,
+		},
+		{
+			name: "mixed failures",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Error: errKeyNotFound, Delay: 10 * time.Millisecond}},
+				"addr2": {"key1": {Error: errTimeout}},
+			},
+			addresses:  []string{"addr1", "addr2"},
+			wantErr:    errTimeout,
+			wantFailed: []string{"addr1", "addr2"},
+		},
+		{
+			name: "context deadline exceeded",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Value: "value1", Delay: time.Second}},
+				"addr2": {"key1": {Value: "value2", Delay: time.Second}},
+			},
+			addresses:   []string{"addr1", "addr2"},
+			wantErr:     context.DeadlineExceeded,
+			wantAll:     context.DeadlineExceeded,
+			wantFailed:  []string{"addr1", "addr2"},
+			wantNotFail: context.Canceled,
+		},
+		{
+			name: "context deadline exceeded after a failure",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Error: errKeyNotFound}},
+				"addr2": {"key1": {Value: "value2", Delay: time.Second}},
+			},
+			addresses:  []string{"addr1", "addr2"},
+			wantErr:    context.DeadlineExceeded,
+			wantFailed: []string{"addr1", "addr2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockGetter(tt.responses)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			_, err := Get(ctx, mock, tt.addresses, "key1")
+			cancel()
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantFailed == nil {
+				return
+			}
+
+			var lookupErr *LookupError
+			if !errors.As(err, &lookupErr) {
+				t.Fatalf("Get() error = %T, want *LookupError", err)
+			}
+
+			var failed []string
+			for _, e := range lookupErr.Errors {
+				failed = append(failed, e.Address)
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("LookupError addresses = %v, want %v", failed, tt.wantFailed)
+			}
+
+			if tt.wantAll != nil && !lookupErr.All(tt.wantAll) {
+				t.Errorf("LookupError.All(%v) = false, want true", tt.wantAll)
+			}
+			if tt.wantNotFail != nil && errors.Is(err, tt.wantNotFail) {
+				t.Errorf("errors.Is(%v, %v) = true, want false", err, tt.wantNotFail)
+			}
+
+			var addrErr *AddressError
+			if !errors.As(err, &addrErr) || addrErr.Address != tt.wantFailed[0] {
+				t.Errorf("errors.As(*AddressError) = %v, want address %s", addrErr, tt.wantFailed[0])
+			}
+		})
+	}
+}
+
+func TestGetQuorum(t *testing.T) {
+	tests := []struct {
+		name          string
+		responses     map[string]map[string]Response
+		addresses     []string
+		key           string
+		quorum        int
+		ttl           time.Duration
+		wantValue     string
+		wantAgreed    []string
+		wantDisagreed map[string]string
+		wantErr       error
+	}{
+		{
+			name: "all replicas agree",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Value: "value1"}},
+				"addr2": {"key1": {Value: "value1", Delay: 10 * time.Millisecond}},
+				"addr3": {"key1": {Value: "value1", Delay: 200 * time.Millisecond}},
+			},
+			addresses:     []string{"addr1", "addr2", "addr3"},
+			key:           "key1",
+			quorum:        2,
+			ttl:           100 * time.Millisecond,
+			wantValue:     "value1",
+			wantAgreed:    []string{"addr1", "addr2"},
+			wantDisagreed: map[string]string{},
+		},
+		{
+			name: "split brain majority wins",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Value: "old"}},
+				"addr2": {"key1": {Value: "new", Delay: 10 * time.Millisecond}},
+				"addr3": {"key1": {Value: "old", Delay: 50 * time.Millisecond}},
+			},
+			addresses:     []string{"addr1", "addr2", "addr3"},
+			key:           "key1",
+			quorum:        2,
+			ttl:           100 * time.Millisecond,
+			wantValue:     "old",
+			wantAgreed:    []string{"addr1", "addr3"},
+			wantDisagreed: map[string]string{"addr2": "new"},
+		},
+		{
+			name: "split brain without majority",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Value: "v1"}},
+				"addr2": {"key1": {Value: "v2"}},
+				"addr3": {"key1": {Value: "v3"}},
+			},
+			addresses:     []string{"addr1", "addr2", "addr3"},
+			key:           "key1",
+			quorum:        2,
+			ttl:           100 * time.Millisecond,
+			wantDisagreed: map[string]string{"addr1": "v1", "addr2": "v2", "addr3": "v3"},
+			wantErr:       ErrNoQuorum,
+		},
+		{
+			name: "quorum impossible before slow replica answers",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Value: "v1"}},
+				"addr2": {"key1": {Value: "v2"}},
+				"addr3": {"key1": {Error: errors.New("connection error")}},
+				"addr4": {"key1": {Value: "v1", Delay: 500 * time.Millisecond}},
+			},
+			addresses:     []string{"addr1", "addr2", "addr3", "addr4"},
+			key:           "key1",
+			quorum:        3,
+			ttl:           100 * time.Millisecond,
+			wantDisagreed: map[string]string{"addr1": "v1", "addr2": "v2"},
+			wantErr:       ErrNoQuorum,
+		},
+		{
+			name: "context cancellation",
+			responses: map[string]map[string]Response{
+				"addr1": {"key1": {Value: "value1"}},
+				"addr2": {"key1": {Value: "value1", Delay: 200 * time.Millisecond}},
+			},
+			addresses:     []string{"addr1", "addr2"},
+			key:           "key1",
+			quorum:        2,
+			ttl:           50 * time.Millisecond,
+			wantDisagreed: map[string]string{"addr1": "value1"},
+			wantErr:       context.DeadlineExceeded,
+		},
+		{
+			name:      "quorum larger than address list",
+			responses: map[string]map[string]Response{},
+			addresses: []string{"addr1"},
+			key:       "key1",
+			quorum:    2,
+			ttl:       50 * time.Millisecond,
+			wantErr:   ErrInvalidQuorum,
+		},
+		{
+			name:      "zero quorum",
+			responses: map[string]map[string]Response{},
+			addresses: []string{"addr1"},
+			key:       "key1",
+			quorum:    0,
+			ttl:       50 * time.Millisecond,
+			wantErr:   ErrInvalidQuorum,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockGetter(tt.responses)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.ttl)
+			got, err := GetQuorum(ctx, mock, tt.addresses, tt.key, tt.quorum)
+			cancel()
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetQuorum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Value != tt.wantValue {
+				t.Errorf("GetQuorum() value = %v, want %v", got.Value, tt.wantValue)
+			}
+
+			sort.Strings(got.Agreed)
+			if len(got.Agreed) != 0 || len(tt.wantAgreed) != 0 {
+				if !reflect.DeepEqual(got.Agreed, tt.wantAgreed) {
+					t.Errorf("GetQuorum() agreed = %v, want %v", got.Agreed, tt.wantAgreed)
+				}
+			}
+			if len(got.Disagreed) != 0 || len(tt.wantDisagreed) != 0 {
+				if !reflect.DeepEqual(got.Disagreed, tt.wantDisagreed) {
+					t.Errorf("GetQuorum() disagreed = %v, want %v", got.Disagreed, tt.wantDisagreed)
+				}
+			}
+		})
+	}
+}
+
+// fakeClock implements Clock. Time moves only when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward and fires every expired waiter.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// BlockUntil waits until n waiters are pending.
+func (c *fakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		pending := len(c.waiters)
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// hedgeGetter records every call. Addresses without a response block until cancelled.
+type hedgeGetter struct {
+	responses map[string]Response
+	calls     chan string
+	cancelled chan string
+}
+
+func newHedgeGetter(responses map[string]Response) *hedgeGetter {
+	return &hedgeGetter{
+		responses: responses,
+		calls:     make(chan string, 10),
+		cancelled: make(chan string, 10),
+	}
+}
+
+func (g *hedgeGetter) Get(ctx context.Context, address, key string) (string, error) {
+	g.calls <- address
+	resp, ok := g.responses[address]
+	if !ok {
+		<-ctx.Done()
+		g.cancelled <- address
+		return "", ctx.Err()
+	}
+	return resp.Value, resp.Error
+}
+
+func (g *hedgeGetter) expectCall(t *testing.T, want string) {
+	t.Helper()
+	select {
+	case got := <-g.calls:
+		if got != want {
+			t.Fatalf("Get() called for %s, want %s", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Get() was not called for %s", want)
+	}
+}
+
+func (g *hedgeGetter) expectNoCall(t *testing.T) {
+	t.Helper()
+	select {
+	case got := <-g.calls:
+		t.Fatalf("unexpected Get() call for %s", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+type hedgeResult struct {
+	value string
+	err   error
+}
+
+func startHedged(getter Getter, clock Clock, addresses []string) <-chan hedgeResult {
+	resCh := make(chan hedgeResult, 1)
+	go func() {
+		val, err := GetHedged(context.Background(), getter, addresses, "key1", HedgePolicy{
+			Delay: 100 * time.Millisecond,
+			Clock: clock,
+		})
+		resCh <- hedgeResult{val, err}
+	}()
+	return resCh
+}
+
+func TestGetHedged(t *testing.T) {
+	t.Run("first address succeeds", func(t *testing.T) {
+		clock := &fakeClock{}
+		getter := newHedgeGetter(map[string]Response{
+			"addr1": {Value: "value1"},
+			"addr2": {Value: "value2"},
+		})
+
+		res := <-startHedged(getter, clock, []string{"addr1", "addr2"})
+		if res.err != nil || res.value != "value1" {
+			t.Fatalf("GetHedged() = %v, %v, want value1", res.value, res.err)
+		}
+		getter.expectCall(t, "addr1")
+		getter.expectNoCall(t)
+	})
+
+	t.Run("next address after delay", func(t *testing.T) {
+		clock := &fakeClock{}
+		getter := newHedgeGetter(map[string]Response{
+			"addr2": {Value: "value2"},
+		})
+
+		resCh := startHedged(getter, clock, []string{"addr1", "addr2", "addr3"})
+		getter.expectCall(t, "addr1")
+		clock.BlockUntil(1)
+
+		clock.Advance(99 * time.Millisecond)
+		getter.expectNoCall(t)
+
+		clock.Advance(time.Millisecond)
+		getter.expectCall(t, "addr2")
+
+		res := <-resCh
+		if res.err != nil || res.value != "value2" {
+			t.Fatalf("GetHedged() = %v, %v, want value2", res.value, res.err)
+		}
+
+		// Loser must be cancelled through the context
+		select {
+		case addr := <-getter.cancelled:
+			if addr != "addr1" {
+				t.Errorf("cancelled %s, want addr1", addr)
+			}
+		case <-time.After(time.Second):
+			t.Error("addr1 request was not cancelled")
+		}
+		getter.expectNoCall(t)
+	})
+
+	t.Run("next address right after error", func(t *testing.T) {
+		clock := &fakeClock{}
+		getter := newHedgeGetter(map[string]Response{
+			"addr1": {Error: errors.New("connection error")},
+			"addr2": {Value: "value2"},
+		})
+
+		res := <-startHedged(getter, clock, []string{"addr1", "addr2"})
+		if res.err != nil || res.value != "value2" {
+			t.Fatalf("GetHedged() = %v, %v, want value2", res.value, res.err)
+		}
+		getter.expectCall(t, "addr1")
+		getter.expectCall(t, "addr2")
+	})
+
+	t.Run("all addresses fail", func(t *testing.T) {
+		clock := &fakeClock{}
+		getter := newHedgeGetter(map[string]Response{
+			"addr1": {Error: errTimeout},
+			"addr2": {Error: errKeyNotFound},
+		})
+
+		res := <-startHedged(getter, clock, []string{"addr1", "addr2"})
+		var lookupErr *LookupError
+		if !errors.As(res.err, &lookupErr) || len(lookupErr.Errors) != 2 {
+			t.Fatalf("GetHedged() error = %v, want *LookupError with 2 errors", res.err)
+		}
+	})
+
+	t.Run("context deadline exceeded", func(t *testing.T) {
+		// addr2 hangs until cancelled, addr3 is never started
+		getter := newHedgeGetter(map[string]Response{
+			"addr1": {Error: errKeyNotFound},
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := GetHedged(ctx, getter, []string{"addr1", "addr2", "addr3"}, "key1", HedgePolicy{Delay: time.Second})
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errKeyNotFound) {
+			t.Fatalf("GetHedged() error = %v, want %v and %v", err, context.DeadlineExceeded, errKeyNotFound)
+		}
+		var lookupErr *LookupError
+		if !errors.As(err, &lookupErr) || len(lookupErr.Errors) != 3 {
+			t.Fatalf("GetHedged() error = %v, want *LookupError with 3 errors", err)
+		}
+	})
+
+	t.Run("empty address list", func(t *testing.T) {
+		res := <-startHedged(newHedgeGetter(nil), &fakeClock{}, nil)
+		if !errors.Is(res.err, ErrNoAddresses) {
+			t.Fatalf("GetHedged() error = %v, want %v", res.err, ErrNoAddresses)
+		}
+	})
+}
+
+func TestLatencyTrackerOrder(t *testing.T) {
+	mock := NewMockGetter(map[string]map[string]Response{
+		"slow":    {"key1": {Value: "slow", Delay: 30 * time.Millisecond}},
+		"fast":    {"key1": {Value: "fast"}},
+		"failing": {"key1": {Error: errors.New("connection error")}},
+		"flaky":   {"key1": {Value: "flaky", Delay: 10 * time.Millisecond}},
+	})
+	tracker := NewLatencyTracker(mock, 0.5)
+
+	for range 3 {
+		for _, address := range []string{"slow", "fast", "failing", "flaky"} {
+			tracker.Get(context.Background(), address, "key1")
+		}
+	}
+	// A single failure makes flaky worse than slow
+	tracker.Get(context.Background(), "flaky", "missing")
+
+	latency, errorRate, ok := tracker.Stats("slow")
+	if !ok || latency < 30*time.Millisecond || errorRate != 0 {
+		t.Errorf("Stats(slow) = %v, %v, %v, want latency >= 30ms and no errors", latency, errorRate, ok)
+	}
+	if _, errorRate, _ := tracker.Stats("failing"); errorRate != 1 {
+		t.Errorf("Stats(failing) error rate = %v, want 1", errorRate)
+	}
+	if _, _, ok := tracker.Stats("unknown"); ok {
+		t.Error("Stats(unknown) ok = true, want false")
+	}
+
+	addresses := []string{"failing", "slow", "unknown", "flaky", "fast"}
+	got := tracker.Order(addresses)
+	want := []string{"unknown", "fast", "slow", "flaky", "failing"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Order() = %v, want %v", got, want)
+	}
+	if addresses[0] != "failing" {
+		t.Errorf("Order() modified addresses: %v", addresses)
+	}
+}
+
+func TestLatencyTrackerLookup(t *testing.T) {
+	mock := NewMockGetter(map[string]map[string]Response{
+		"addr1": {"key1": {Value: "value1", Delay: 50 * time.Millisecond}},
+		"addr2": {"key1": {Value: "value2"}},
+	})
+	tracker := NewLatencyTracker(mock, DefaultAlpha)
+
+	addresses := []string{"addr1", "addr2"}
+	for _, address := range addresses {
+		tracker.Get(context.Background(), address, "key1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	got, err := tracker.Lookup(ctx, addresses, "key1", HedgePolicy{Delay: 500 * time.Millisecond})
+	if err != nil || got != "value2" {
+		t.Fatalf("Lookup() = %v, %v, want value2 from the fastest address", got, err)
+	}
+}
+
+func TestLatencyTrackerLookupColdStart(t *testing.T) {
+	mock := NewMockGetter(map[string]map[string]Response{
+		"slow": {"key1": {Value: "slow", Delay: 200 * time.Millisecond}},
+		"fast": {"key1": {Value: "fast", Delay: time.Millisecond}},
+	})
+	tracker := NewLatencyTracker(mock, DefaultAlpha)
+
+	// Slow address always loses, so only cancelled requests tell it's slow
+	addresses := []string{"slow", "fast"}
+	for range 5 {
+		got, err := tracker.Lookup(context.Background(), addresses, "key1", HedgePolicy{Delay: 20 * time.Millisecond})
+		if err != nil || got != "fast" {
+			t.Fatalf("Lookup() = %v, %v, want fast", got, err)
+		}
+	}
+
+	latency, errorRate, ok := tracker.Stats("slow")
+	if !ok || latency < 20*time.Millisecond || errorRate != 0 {
+		t.Errorf("Stats(slow) = %v, %v, %v, want latency >= 20ms and no errors", latency, errorRate, ok)
+	}
+	if got, want := tracker.Order(addresses), []string{"fast", "slow"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Order() = %v, want %v", got, want)
+	}
+}
+
+func TestLatencyTrackerIgnoresCancelled(t *testing.T) {
+	mock := NewMockGetter(map[string]map[string]Response{
+		"addr1": {"key1": {Value: "value1", Delay: 200 * time.Millisecond}},
+	})
+	tracker := NewLatencyTracker(mock, DefaultAlpha)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := tracker.Get(ctx, "addr1", "key1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, _, ok := tracker.Stats("addr1"); ok {
+		t.Error("cancelled request was recorded")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"iter"
+
+	"golang.org/x/tour/tree"
+)
+
+// Divergence is the first position where in-order sequences of two trees differ.
+type Divergence struct {
+	// Index of the position. All values before it are equal in both trees.
+	Index int
+	// Values at Index. HasLeft or HasRight is false if that tree has no more values.
+	Left, Right       int
+	HasLeft, HasRight bool
+}
+
+// TreeDiff is the difference between values of two trees.
+// Values are sorted, duplicates are counted.
+type TreeDiff struct {
+	OnlyLeft  []int
+	OnlyRight []int
+	// First is nil if trees contain the same values.
+	First *Divergence
+}
+
+// Diff merges in-order sequences of the trees t1 and t2
+// and collects values present in only one of them.
+func Diff(t1, t2 *tree.Tree) TreeDiff {
+	next1, stop1 := iter.Pull(All(t1))
+	defer stop1()
+	next2, stop2 := iter.Pull(All(t2))
+	defer stop2()
+
+	var diff TreeDiff
+	var index int
+	v1, ok1 := next1()
+	v2, ok2 := next2()
+	for ok1 || ok2 {
+		if ok1 && ok2 && v1 == v2 {
+			index++
+			v1, ok1 = next1()
+			v2, ok2 = next2()
+			continue
+		}
+
+		if diff.First == nil {
+			diff.First = &Divergence{Index: index, Left: v1, Right: v2, HasLeft: ok1, HasRight: ok2}
+		}
+		// Smaller value can't appear in the other tree anymore
+		if !ok2 || (ok1 && v1 < v2) {
+			diff.OnlyLeft = append(diff.OnlyLeft, v1)
+			v1, ok1 = next1()
+		} else {
+			diff.OnlyRight = append(diff.OnlyRight, v2)
+			v2, ok2 = next2()
+		}
+	}
+	return diff
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"iter"
+
+	"golang.org/x/tour/tree"
+)
+
+// Walker is any tree that can expose its values in order.
+type Walker[T cmp.Ordered] interface {
+	All() iter.Seq[T]
+}
+
+// Tree is a binary search tree holding values of any ordered type.
+// A nil *Tree is an empty tree.
+type Tree[T cmp.Ordered] struct {
+	Left  *Tree[T]
+	Value T
+	Right *Tree[T]
+}
+
+// Insert adds v to the tree t and returns the root.
+func (t *Tree[T]) Insert(v T) *Tree[T] {
+	if t == nil {
+		return &Tree[T]{Value: v}
+	}
+	if v < t.Value {
+		t.Left = t.Left.Insert(v)
+	} else {
+		t.Right = t.Right.Insert(v)
+	}
+	return t
+}
+
+// All returns an iterator over values of the tree t in order.
+func (t *Tree[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.inOrder(yield)
+	}
+}
+
+func (t *Tree[T]) inOrder(yield func(T) bool) bool {
+	if t == nil {
+		return true
+	}
+	return t.Left.inOrder(yield) && yield(t.Value) && t.Right.inOrder(yield)
+}
+
+// TourTree adapts tree.Tree to Walker.
+type TourTree struct {
+	*tree.Tree
+}
+
+func (t TourTree) All() iter.Seq[int] {
+	return All(t.Tree)
+}
+
+// WalkOrdered walks w sending all values
+// from the tree to the channel ch.
+// It stops early when ctx is done. ch is closed in both cases.
+func WalkOrdered[T cmp.Ordered](ctx context.Context, w Walker[T], ch chan<- T) {
+	defer close(ch)
+	for v := range w.All() {
+		select {
+		case ch <- v:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SameOrdered determines whether w1 and w2 contain the same values.
+func SameOrdered[T cmp.Ordered](w1, w2 Walker[T]) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch1, ch2 := make(chan T), make(chan T)
+	go WalkOrdered(ctx, w1, ch1)
+	go WalkOrdered(ctx, w2, ch2)
+	for {
+		v1, ok1 := <-ch1
+		v2, ok2 := <-ch2
+		if ok1 != ok2 || v1 != v2 {
+			return false
+		}
+		if !ok1 {
+			return true
+		}
+	}
+}
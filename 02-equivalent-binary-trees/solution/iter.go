@@ -0,0 +1,95 @@
+package main
+
+import (
+	"iter"
+
+	"golang.org/x/tour/tree"
+)
+
+// All returns an iterator over values of the tree t in order.
+func All(t *tree.Tree) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		inOrder(t, yield)
+	}
+}
+
+// PreOrder returns an iterator over values of the tree t:
+// node first, then left and right subtrees.
+func PreOrder(t *tree.Tree) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		preOrder(t, yield)
+	}
+}
+
+// PostOrder returns an iterator over values of the tree t:
+// left and right subtrees first, then node.
+func PostOrder(t *tree.Tree) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		postOrder(t, yield)
+	}
+}
+
+// LevelOrder returns an iterator over values of the tree t
+// level by level, from left to right.
+func LevelOrder(t *tree.Tree) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		if t == nil {
+			return
+		}
+		queue := []*tree.Tree{t}
+		for len(queue) > 0 {
+			node := queue[0]
+			queue = queue[1:]
+			if !yield(node.Value) {
+				return
+			}
+			if node.Left != nil {
+				queue = append(queue, node.Left)
+			}
+			if node.Right != nil {
+				queue = append(queue, node.Right)
+			}
+		}
+	}
+}
+
+// Traversal helpers return false when yield asked to stop
+func inOrder(t *tree.Tree, yield func(int) bool) bool {
+	if t == nil {
+		return true
+	}
+	return inOrder(t.Left, yield) && yield(t.Value) && inOrder(t.Right, yield)
+}
+
+func preOrder(t *tree.Tree, yield func(int) bool) bool {
+	if t == nil {
+		return true
+	}
+	return yield(t.Value) && preOrder(t.Left, yield) && preOrder(t.Right, yield)
+}
+
+func postOrder(t *tree.Tree, yield func(int) bool) bool {
+	if t == nil {
+		return true
+	}
+	return postOrder(t.Left, yield) && postOrder(t.Right, yield) && yield(t.Value)
+}
+
+// SameIter is Same without goroutines and channels.
+func SameIter(t1, t2 *tree.Tree) bool {
+	next1, stop1 := iter.Pull(All(t1))
+	defer stop1()
+	next2, stop2 := iter.Pull(All(t2))
+	defer stop2()
+
+	for {
+		v1, ok1 := next1()
+		v2, ok2 := next2()
+		if ok1 != ok2 || v1 != v2 {
+			return false
+		}
+		if !ok1 {
+			return true
+		}
+	}
+}
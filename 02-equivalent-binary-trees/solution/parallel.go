@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"runtime"
+
+	"golang.org/x/tour/tree"
+)
+
+const (
+	// Workers send values in batches, so channel operations don't dominate
+	batchSize = 512
+	// How many batches a worker can produce ahead of the reader
+	batchBuffer = 8
+)
+
+// WalkIterative walks the tree t in order with an explicit stack
+// instead of recursion, so degenerate trees don't grow the goroutine stack.
+// It stops early when ctx is done. ch is closed in both cases.
+func WalkIterative(ctx context.Context, t *tree.Tree, ch chan<- int) {
+	defer close(ch)
+	walkStack(t, sender(ctx, ch))
+}
+
+// WalkParallel walks the tree t in order. Right subtrees are walked
+// in separate goroutines when both children of a node have at least
+// threshold nodes, with a bounded number of goroutines at a time.
+// Values are still sent to ch in order.
+// It stops early when ctx is done. ch is closed in both cases.
+func WalkParallel(ctx context.Context, t *tree.Tree, ch chan<- int, threshold int) {
+	defer close(ch)
+
+	// Workers must stop when we return, even if ctx is never done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := &parallelWalker{
+		ctx:       ctx,
+		threshold: max(threshold, 1),
+		workers:   make(chan struct{}, maxWorkers()),
+		free:      make(chan []int, maxWorkers()*batchBuffer),
+	}
+	emit := sender(ctx, ch)
+	w.walk(t, emit, func(batch []int) bool {
+		for _, v := range batch {
+			if !emit(v) {
+				return false
+			}
+		}
+		w.recycle(batch)
+		return true
+	})
+}
+
+// maxWorkers bounds the number of workers of a single walk.
+// Every worker buffers up to batchBuffer batches ahead of the reader,
+// so without a bound memory would grow with the tree.
+func maxWorkers() int {
+	return 4 * runtime.GOMAXPROCS(0)
+}
+
+func sender(ctx context.Context, ch chan<- int) func(int) bool {
+	return func(v int) bool {
+		select {
+		case ch <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// walkStack returns false if emit asked to stop
+func walkStack(t *tree.Tree, emit func(int) bool) bool {
+	var stack []*tree.Tree
+	for t != nil || len(stack) > 0 {
+		for t != nil {
+			stack = append(stack, t)
+			t = t.Left
+		}
+		t = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !emit(t.Value) {
+			return false
+		}
+		t = t.Right
+	}
+	return true
+}
+
+type frame struct {
+	node *tree.Tree
+	// right subtree walked by a worker, nil if it's walked here
+	right <-chan []int
+	// right subtree has fewer than threshold nodes, no need to check it again
+	rightSmall bool
+}
+
+type parallelWalker struct {
+	ctx       context.Context
+	threshold int
+	// workers holds a token for every running worker
+	workers chan struct{}
+	// free holds batches already sent to ch, so workers reuse them
+	free chan []int
+}
+
+// walk is walkStack that hands big right subtrees to workers.
+// Batches from workers are passed to emitBatch as is.
+// It returns false if emit or emitBatch asked to stop.
+func (w *parallelWalker) walk(t *tree.Tree, emit func(int) bool, emitBatch func([]int) bool) bool {
+	var stack []frame
+	// small is true if t is known to have fewer than threshold nodes
+	var small bool
+	for {
+		for t != nil {
+			f := frame{node: t, rightSmall: small}
+			leftSmall := small
+			switch {
+			case small:
+			case t.Left == nil || t.Right == nil:
+				// Nothing to split, it's a cheap way to tell the nil side is small
+				leftSmall, f.rightSmall = t.Left == nil, t.Right == nil
+			case !w.acquire():
+				// All workers are busy, so there is no point in counting
+			default:
+				leftSmall, f.rightSmall = smallTrees(t.Left, t.Right, w.threshold)
+				if !leftSmall && !f.rightSmall {
+					f.right = w.spawn(t.Right)
+				} else {
+					w.release()
+				}
+			}
+			stack = append(stack, f)
+			t, small = t.Left, leftSmall
+		}
+
+		if len(stack) == 0 {
+			return true
+		}
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !emit(f.node.Value) {
+			return false
+		}
+
+		if f.right == nil {
+			t, small = f.node.Right, f.rightSmall
+			continue
+		}
+		for batch := range f.right {
+			if !emitBatch(batch) {
+				return false
+			}
+		}
+	}
+}
+
+// acquire reserves a worker without waiting, returns false if all are busy.
+func (w *parallelWalker) acquire() bool {
+	select {
+	case w.workers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *parallelWalker) release() {
+	<-w.workers
+}
+
+// batch returns an empty batch of at least size capacity.
+func (w *parallelWalker) batch(size int) []int {
+	select {
+	case b := <-w.free:
+		if cap(b) >= size {
+			return b[:0]
+		}
+	default:
+	}
+	return make([]int, 0, size)
+}
+
+// recycle returns a batch nobody reads anymore.
+func (w *parallelWalker) recycle(b []int) {
+	select {
+	case w.free <- b:
+	default:
+	}
+}
+
+// spawn walks t in a new goroutine and sends values in batches.
+// The worker must be acquired, it's released when the walk is done.
+func (w *parallelWalker) spawn(t *tree.Tree) <-chan []int {
+	out := make(chan []int, batchBuffer)
+	go func() {
+		defer close(out)
+		defer w.release()
+		flush := func(batch []int) bool {
+			select {
+			case out <- batch:
+				return true
+			case <-w.ctx.Done():
+				return false
+			}
+		}
+
+		// The subtree may be much smaller than a batch, so batches
+		// start at threshold and grow up to batchSize
+		size := min(w.threshold, batchSize)
+		batch := w.batch(size)
+		// Reader owns the sent batch, so a new one is needed
+		flushPending := func() bool {
+			if len(batch) == 0 {
+				return true
+			}
+			full := batch
+			size = min(2*size, batchSize)
+			batch = w.batch(size)
+			return flush(full)
+		}
+
+		ok := w.walk(t, func(v int) bool {
+			batch = append(batch, v)
+			if len(batch) < cap(batch) {
+				return true
+			}
+			return flushPending()
+		}, func(b []int) bool {
+			// Batches of nested workers are forwarded without copying
+			return flushPending() && flush(b)
+		})
+		if ok {
+			flushPending()
+		}
+	}()
+	return out
+}
+
+// smallTrees reports whether a and b have fewer than n nodes.
+// Trees are counted in turns, so it stops as soon as the smaller one is counted.
+// Only one of the results can be true.
+// Both trees must not be nil.
+func smallTrees(a, b *tree.Tree, n int) (aSmall, bSmall bool) {
+	ca, cb := counter{stack: []*tree.Tree{a}}, counter{stack: []*tree.Tree{b}}
+	for ca.count < n || cb.count < n {
+		if ca.count < n && !ca.next() {
+			return true, false
+		}
+		if cb.count < n && !cb.next() {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+type counter struct {
+	stack []*tree.Tree
+	count int
+}
+
+// next counts one more node, returns false if there are none left
+func (c *counter) next() bool {
+	if len(c.stack) == 0 {
+		return false
+	}
+	t := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	c.count++
+	if t.Left != nil {
+		c.stack = append(c.stack, t.Left)
+	}
+	if t.Right != nil {
+		c.stack = append(c.stack, t.Right)
+	}
+	return true
+}
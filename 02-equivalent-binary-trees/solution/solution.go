@@ -1,39 +1,29 @@
 package main
 
 import (
+	"context"
+
 	"golang.org/x/tour/tree"
 )
 
 // Walk walks the tree t sending all values
 // from the tree to the channel ch.
 func Walk(t *tree.Tree, ch chan int) {
-	defer close(ch)
-	goWalk(t, ch)
+	WalkContext(context.Background(), t, ch)
 }
 
-func goWalk(t *tree.Tree, ch chan int) {
-	if t == nil {
-		return
-	}
-	goWalk(t.Left, ch)
-	ch <- t.Value
-	goWalk(t.Right, ch)
+// WalkContext walks the tree t sending all values
+// from the tree to the channel ch.
+// It stops early when ctx is done. ch is closed in both cases.
+func WalkContext(ctx context.Context, t *tree.Tree, ch chan<- int) {
+	WalkOrdered(ctx, TourTree{t}, ch)
 }
 
 // Same determines whether the trees
 // t1 and t2 contain the same values.
+// Walkers must not leak when trees are different.
 func Same(t1, t2 *tree.Tree) bool {
-	ch1, ch2 := make(chan int), make(chan int)
-	go Walk(t1, ch1)
-	go Walk(t2, ch2)
-	for {
-		v1, ok1 := <-ch1
-		v2, ok2 := <-ch2
-		if ok1 != ok2 || v1 != v2 {
-			return false
-		}
-		if !ok1 {
-			return true
-		}
-	}
+	// SameOrdered cancels both walkers on return, otherwise they would stuck
+	// forever on sending to channels nobody reads after a mismatch
+	return SameOrdered[int](TourTree{t1}, TourTree{t2})
 }
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"iter"
+
 	"golang.org/x/tour/tree"
 )
 
@@ -9,8 +13,124 @@ import (
 func Walk(t *tree.Tree, ch chan int) {
 }
 
+// WalkContext walks the tree t sending all values
+// from the tree to the channel ch.
+// It stops early when ctx is done. ch is closed in both cases.
+func WalkContext(ctx context.Context, t *tree.Tree, ch chan<- int) {
+}
+
 // Same determines whether the trees
 // t1 and t2 contain the same values.
+// Walkers must not leak when trees are different.
 func Same(t1, t2 *tree.Tree) bool {
 	return false
 }
+
+// WalkIterative walks the tree t in order with an explicit stack
+// instead of recursion, so degenerate trees don't grow the goroutine stack.
+// It stops early when ctx is done. ch is closed in both cases.
+func WalkIterative(ctx context.Context, t *tree.Tree, ch chan<- int) {
+}
+
+// WalkParallel walks the tree t in order. Right subtrees are walked
+// in separate goroutines when both children of a node have at least
+// threshold nodes, with a bounded number of goroutines at a time.
+// Values are still sent to ch in order.
+// It stops early when ctx is done. ch is closed in both cases.
+func WalkParallel(ctx context.Context, t *tree.Tree, ch chan<- int, threshold int) {
+}
+
+// All returns an iterator over values of the tree t in order.
+func All(t *tree.Tree) iter.Seq[int] {
+	return func(yield func(int) bool) {}
+}
+
+// PreOrder returns an iterator over values of the tree t:
+// node first, then left and right subtrees.
+func PreOrder(t *tree.Tree) iter.Seq[int] {
+	return func(yield func(int) bool) {}
+}
+
+// PostOrder returns an iterator over values of the tree t:
+// left and right subtrees first, then node.
+func PostOrder(t *tree.Tree) iter.Seq[int] {
+	return func(yield func(int) bool) {}
+}
+
+// LevelOrder returns an iterator over values of the tree t
+// level by level, from left to right.
+func LevelOrder(t *tree.Tree) iter.Seq[int] {
+	return func(yield func(int) bool) {}
+}
+
+// SameIter is Same without goroutines and channels.
+func SameIter(t1, t2 *tree.Tree) bool {
+	return false
+}
+
+// Divergence is the first position where in-order sequences of two trees differ.
+type Divergence struct {
+	// Index of the position. All values before it are equal in both trees.
+	Index int
+	// Values at Index. HasLeft or HasRight is false if that tree has no more values.
+	Left, Right       int
+	HasLeft, HasRight bool
+}
+
+// TreeDiff is the difference between values of two trees.
+// Values are sorted, duplicates are counted.
+type TreeDiff struct {
+	OnlyLeft  []int
+	OnlyRight []int
+	// First is nil if trees contain the same values.
+	First *Divergence
+}
+
+// Diff merges in-order sequences of the trees t1 and t2
+// and collects values present in only one of them.
+func Diff(t1, t2 *tree.Tree) TreeDiff {
+	return TreeDiff{}
+}
+
+// Walker is any tree that can expose its values in order.
+type Walker[T cmp.Ordered] interface {
+	All() iter.Seq[T]
+}
+
+// Tree is a binary search tree holding values of any ordered type.
+// A nil *Tree is an empty tree.
+type Tree[T cmp.Ordered] struct {
+	Left  *Tree[T]
+	Value T
+	Right *Tree[T]
+}
+
+// Insert adds v to the tree t and returns the root.
+func (t *Tree[T]) Insert(v T) *Tree[T] {
+	return t
+}
+
+// All returns an iterator over values of the tree t in order.
+func (t *Tree[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {}
+}
+
+// TourTree adapts tree.Tree to Walker.
+type TourTree struct {
+	*tree.Tree
+}
+
+func (t TourTree) All() iter.Seq[int] {
+	return All(t.Tree)
+}
+
+// WalkOrdered walks w sending all values
+// from the tree to the channel ch.
+// It stops early when ctx is done. ch is closed in both cases.
+func WalkOrdered[T cmp.Ordered](ctx context.Context, w Walker[T], ch chan<- T) {
+}
+
+// SameOrdered determines whether w1 and w2 contain the same values.
+func SameOrdered[T cmp.Ordered](w1, w2 Walker[T]) bool {
+	return false
+}
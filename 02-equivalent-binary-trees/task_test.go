@@ -1,9 +1,16 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
 	"reflect"
+	"runtime"
+	"slices"
 	"sort"
 	"testing"
+	"time"
 
 	"golang.org/x/tour/tree"
 )
@@ -126,6 +133,416 @@ func TestSame(t *testing.T) {
 			if got != tt.want {
 				t.Errorf("Same() = %v, want %v", got, tt.want)
 			}
+
+			got = SameIter(tt.t1, tt.t2)
+			if got != tt.want {
+				t.Errorf("SameIter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalkContextStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan int)
+	done := make(chan struct{})
+	go func() {
+		WalkContext(ctx, tree.New(1), ch)
+		close(done)
+	}()
+
+	for i := 1; i <= 3; i++ {
+		if v := <-ch; v != i {
+			t.Fatalf("WalkContext() got = %v, want %v", v, i)
+		}
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WalkContext() did not stop after cancel")
+	}
+	if _, ok := <-ch; ok {
+		t.Error("WalkContext() did not close the channel")
+	}
+}
+
+func TestSameNoLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		if Same(tree.New(1), tree.New(2)) {
+			t.Fatal("Same() = true, want false")
+		}
+	}
+
+	// Stopped walkers need some time to exit
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked: before = %d, after = %d", before, after)
+	}
+}
+
+// balanced builds a balanced tree holding values from lo to hi.
+func balanced(lo, hi int) *tree.Tree {
+	if lo > hi {
+		return nil
+	}
+	mid := lo + (hi-lo)/2
+	return &tree.Tree{Left: balanced(lo, mid-1), Value: mid, Right: balanced(mid+1, hi)}
+}
+
+func TestTraversals(t *testing.T) {
+	//       4
+	//     /   \
+	//    2     6
+	//   / \   / \
+	//  1   3 5   7
+	root := balanced(1, 7)
+
+	tests := []struct {
+		name string
+		seq  func(*tree.Tree) iter.Seq[int]
+		want []int
+	}{
+		{name: "in order", seq: All, want: []int{1, 2, 3, 4, 5, 6, 7}},
+		{name: "pre order", seq: PreOrder, want: []int{4, 2, 1, 3, 6, 5, 7}},
+		{name: "post order", seq: PostOrder, want: []int{1, 3, 2, 5, 7, 6, 4}},
+		{name: "level order", seq: LevelOrder, want: []int{4, 2, 6, 1, 3, 5, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slices.Collect(tt.seq(root)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got = %v, want %v", got, tt.want)
+			}
+
+			if got := slices.Collect(tt.seq(nil)); len(got) != 0 {
+				t.Errorf("nil tree got = %v, want empty", got)
+			}
+
+			var got []int
+			for v := range tt.seq(root) {
+				got = append(got, v)
+				if len(got) == 3 {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want[:3]) {
+				t.Errorf("with break got = %v, want %v", got, tt.want[:3])
+			}
+		})
+	}
+}
+
+func TestAllRandomTree(t *testing.T) {
+	got := slices.Collect(All(tree.New(3)))
+	want := []int{3, 6, 9, 12, 15, 18, 21, 24, 27, 30}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+}
+
+// fromValues builds a tree from values in insertion order
+func fromValues(values ...int) *tree.Tree {
+	var root *tree.Tree
+	for _, v := range values {
+		root = insert(root, v)
+	}
+	return root
+}
+
+func insert(t *tree.Tree, v int) *tree.Tree {
+	if t == nil {
+		return &tree.Tree{Value: v}
+	}
+	if v < t.Value {
+		t.Left = insert(t.Left, v)
+	} else {
+		t.Right = insert(t.Right, v)
+	}
+	return t
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		t1   *tree.Tree
+		t2   *tree.Tree
+		want TreeDiff
+	}{
+		{
+			name: "same trees",
+			t1:   tree.New(1),
+			t2:   tree.New(1),
+			want: TreeDiff{},
+		},
+		{
+			name: "both nil trees",
+			want: TreeDiff{},
+		},
+		{
+			name: "different trees",
+			t1:   fromValues(3, 1, 2, 5),
+			t2:   fromValues(4, 2, 1, 6, 5),
+			want: TreeDiff{
+				OnlyLeft:  []int{3},
+				OnlyRight: []int{4, 6},
+				First:     &Divergence{Index: 2, Left: 3, Right: 4, HasLeft: true, HasRight: true},
+			},
+		},
+		{
+			name: "left is prefix of right",
+			t1:   fromValues(2, 1),
+			t2:   fromValues(2, 1, 3),
+			want: TreeDiff{
+				OnlyRight: []int{3},
+				First:     &Divergence{Index: 2, Right: 3, HasRight: true},
+			},
+		},
+		{
+			name: "nil second tree",
+			t1:   fromValues(2, 1),
+			want: TreeDiff{
+				OnlyLeft: []int{1, 2},
+				First:    &Divergence{Index: 0, Left: 1, HasLeft: true},
+			},
+		},
+		{
+			name: "duplicates",
+			t1:   fromValues(2, 1, 2),
+			t2:   fromValues(1, 2, 3),
+			want: TreeDiff{
+				OnlyLeft:  []int{2},
+				OnlyRight: []int{3},
+				First:     &Divergence{Index: 2, Left: 2, Right: 3, HasLeft: true, HasRight: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(tt.t1, tt.t2)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+				if got.First != nil {
+					t.Errorf("Diff().First = %+v", *got.First)
+				}
+			}
+		})
+	}
+}
+
+// degenerate builds a linked-list-shaped tree holding values from 1 to n.
+func degenerate(n int) *tree.Tree {
+	var root *tree.Tree
+	for v := n; v > 0; v-- {
+		root = &tree.Tree{Value: v, Right: root}
+	}
+	return root
+}
+
+type walkFunc func(ctx context.Context, t *tree.Tree, ch chan<- int)
+
+func walkers() map[string]walkFunc {
+	return map[string]walkFunc{
+		"context":   WalkContext,
+		"iterative": WalkIterative,
+		"parallel/1": func(ctx context.Context, t *tree.Tree, ch chan<- int) {
+			WalkParallel(ctx, t, ch, 1)
+		},
+		"parallel/100": func(ctx context.Context, t *tree.Tree, ch chan<- int) {
+			WalkParallel(ctx, t, ch, 100)
+		},
+	}
+}
+
+func collect(walk walkFunc, t *tree.Tree) []int {
+	ch := make(chan int)
+	go walk(context.Background(), t, ch)
+	var result []int
+	for v := range ch {
+		result = append(result, v)
+	}
+	return result
+}
+
+func TestWalkers(t *testing.T) {
+	trees := []struct {
+		name string
+		tree *tree.Tree
+		want []int
+	}{
+		{name: "nil", tree: nil, want: nil},
+		{name: "random", tree: tree.New(1), want: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{name: "balanced", tree: balanced(1, 10000), want: slices.Collect(All(balanced(1, 10000)))},
+		{name: "degenerate", tree: degenerate(10000), want: slices.Collect(All(balanced(1, 10000)))},
+		{name: "left degenerate", tree: fromValues(5, 4, 3, 2, 1), want: []int{1, 2, 3, 4, 5}},
+	}
+
+	for name, walk := range walkers() {
+		for _, tt := range trees {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				if got := collect(walk, tt.tree); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %d values, want %d in order", len(got), len(tt.want))
+				}
+			})
+		}
+	}
+}
+
+func TestWalkParallelStops(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := make(chan int)
+		go WalkParallel(ctx, balanced(1, 100000), ch, 100)
+		for j := 1; j <= 1000; j++ {
+			if v := <-ch; v != j {
+				t.Fatalf("WalkParallel() got = %v, want %v", v, j)
+			}
+		}
+		cancel()
+		for range ch {
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked: before = %d, after = %d", before, after)
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	const size = 1_000_000
+	trees := []struct {
+		name string
+		tree *tree.Tree
+	}{
+		{name: "balanced", tree: balanced(1, size)},
+		{name: "degenerate", tree: degenerate(size)},
+	}
+
+	for _, tt := range trees {
+		for _, name := range []string{"context", "iterative", "parallel/100"} {
+			walk := walkers()[name]
+			b.Run(tt.name+"/"+name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					ch := make(chan int, 1024)
+					go walk(context.Background(), tt.tree, ch)
+					for range ch {
+					}
+				}
+			})
+		}
+		b.Run(tt.name+"/parallel/10000", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ch := make(chan int, 1024)
+				go WalkParallel(context.Background(), tt.tree, ch, 10000)
+				for range ch {
+				}
+			}
+		})
+	}
+}
+
+// sortedSlice is a Walker that is not a tree at all
+type sortedSlice[T cmp.Ordered] []T
+
+func (s sortedSlice[T]) All() iter.Seq[T] {
+	return slices.Values(s)
+}
+
+func newTree[T cmp.Ordered](values ...T) *Tree[T] {
+	var t *Tree[T]
+	for _, v := range values {
+		t = t.Insert(v)
+	}
+	return t
+}
+
+func TestGenericTree(t *testing.T) {
+	got := slices.Collect(newTree("go", "c", "rust", "ada").All())
+	want := []string{"ada", "c", "go", "rust"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tree.All() = %v, want %v", got, want)
+	}
+
+	var empty *Tree[string]
+	if got := slices.Collect(empty.All()); len(got) != 0 {
+		t.Errorf("nil Tree.All() = %v, want empty", got)
+	}
+}
+
+func TestWalkOrdered(t *testing.T) {
+	ch := make(chan float64)
+	go WalkOrdered[float64](context.Background(), newTree(2.5, 0.5, 1.5), ch)
+
+	var got []float64
+	for v := range ch {
+		got = append(got, v)
+	}
+	if want := []float64{0.5, 1.5, 2.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkOrdered() = %v, want %v", got, want)
+	}
+}
+
+func TestSameOrdered(t *testing.T) {
+	t.Run("strings", func(t *testing.T) {
+		t1 := newTree("b", "a", "c")
+		t2 := newTree("a", "b", "c")
+		t3 := newTree("a", "b", "d")
+		if !SameOrdered[string](t1, t2) {
+			t.Error("SameOrdered(t1, t2) = false, want true")
+		}
+		if SameOrdered[string](t1, t3) {
+			t.Error("SameOrdered(t1, t3) = true, want false")
+		}
+		if !SameOrdered[string](t1, sortedSlice[string]{"a", "b", "c"}) {
+			t.Error("SameOrdered(t1, slice) = false, want true")
+		}
+	})
+
+	t.Run("tour tree adapter", func(t *testing.T) {
+		generic := newTree(10, 9, 8, 7, 6, 5, 4, 3, 2, 1)
+		if !SameOrdered[int](TourTree{tree.New(1)}, generic) {
+			t.Error("SameOrdered(tree.New(1), generic) = false, want true")
+		}
+		if SameOrdered[int](TourTree{tree.New(2)}, generic) {
+			t.Error("SameOrdered(tree.New(2), generic) = true, want false")
+		}
+		if !SameOrdered[int](TourTree{nil}, (*Tree[int])(nil)) {
+			t.Error("SameOrdered(nil, nil) = false, want true")
+		}
+	})
+}
+
+func benchmarkSame(b *testing.B, same func(t1, t2 *tree.Tree) bool, size int) {
+	t1, t2 := balanced(1, size), balanced(1, size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !same(t1, t2) {
+			b.Fatal("trees must be the same")
+		}
+	}
+}
+
+func BenchmarkSame(b *testing.B) {
+	for _, size := range []int{10, 1000} {
+		b.Run(fmt.Sprintf("channels/%d", size), func(b *testing.B) {
+			benchmarkSame(b, Same, size)
+		})
+		b.Run(fmt.Sprintf("iter/%d", size), func(b *testing.B) {
+			benchmarkSame(b, SameIter, size)
 		})
 	}
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	ErrCheckpointMismatch = errors.New("checkpoint belongs to another crawl")
+	ErrCheckpointWrite    = errors.New("checkpoint write failed")
+)
+
+// checkpointRecord is a single line of a checkpoint file.
+// Kind is one of:
+//   - "crawl": the first line, URL and Depth of the crawl.
+//   - "page": a fetched page. Its parent and depth are found again
+//     on restart, by following links of restored pages from the start URL.
+type checkpointRecord struct {
+	Kind  string   `json:"kind"`
+	URL   string   `json:"url,omitempty"`
+	Depth int      `json:"depth,omitempty"`
+	Body  string   `json:"body,omitempty"`
+	Links []string `json:"links,omitempty"`
+	Err   string   `json:"err,omitempty"`
+}
+
+// checkpoint is an append-only journal of a crawl.
+// Only the goroutine running the crawl uses it, so there is no lock.
+type checkpoint struct {
+	f        *os.File
+	w        *bufio.Writer
+	interval time.Duration
+	flushed  time.Time
+	// err is the first write error, the crawl goes on without checkpoints
+	err error
+
+	// started and pages are restored from the file
+	started bool
+	pages   map[string]*checkpointRecord
+}
+
+// openCheckpoint restores the journal at path, or creates it.
+// A line cut by a crash is dropped.
+func openCheckpoint(path, url string, depth int, interval time.Duration) (*checkpoint, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{f: f, interval: interval, pages: make(map[string]*checkpointRecord)}
+	if err := cp.restore(url, depth); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	cp.w = bufio.NewWriter(f)
+	cp.flushed = time.Now()
+
+	if !cp.started {
+		cp.write(checkpointRecord{Kind: "crawl", URL: url, Depth: depth}, false)
+	}
+	return cp, nil
+}
+
+func (cp *checkpoint) restore(url string, depth int) error {
+	data, err := io.ReadAll(cp.f)
+	if err != nil {
+		return err
+	}
+
+	// valid is the length of the journal up to the last complete line
+	var valid int64
+	for len(data) > 0 {
+		line, rest, ok := bytes.Cut(data, []byte("\n"))
+		var rec checkpointRecord
+		if !ok || json.Unmarshal(line, &rec) != nil {
+			break
+		}
+		data = rest
+		valid += int64(len(line)) + 1
+
+		switch rec.Kind {
+		case "crawl":
+			if rec.URL != url || rec.Depth != depth {
+				return fmt.Errorf("%w: started at %s with depth %d", ErrCheckpointMismatch, rec.URL, rec.Depth)
+			}
+			cp.started = true
+		case "page":
+			cp.pages[rec.URL] = &rec
+		}
+	}
+
+	if err := cp.f.Truncate(valid); err != nil {
+		return err
+	}
+	_, err = cp.f.Seek(valid, io.SeekStart)
+	return err
+}
+
+// page returns the restored result of t, or nil.
+func (cp *checkpoint) page(t task) *fetchResult {
+	if cp == nil {
+		return nil
+	}
+	rec := cp.pages[t.url]
+	if rec == nil {
+		return nil
+	}
+	res := &fetchResult{task: t, body: rec.Body, urls: rec.Links}
+	if rec.Err != "" {
+		res.err = errors.New(rec.Err)
+	}
+	return res
+}
+
+// writePage records a fetched page. Pages are flushed
+// once in interval, the ones not flushed are fetched again on restart.
+func (cp *checkpoint) writePage(res *fetchResult) {
+	if cp == nil {
+		return
+	}
+	rec := checkpointRecord{Kind: "page", URL: res.url, Body: res.body, Links: res.urls}
+	if res.err != nil {
+		rec.Err = res.err.Error()
+	}
+	cp.write(rec, time.Since(cp.flushed) >= cp.interval)
+}
+
+func (cp *checkpoint) write(rec checkpointRecord, flush bool) {
+	if cp.err != nil {
+		return
+	}
+	// json.Marshal escapes newlines, so a record is always a single line
+	line, err := json.Marshal(rec)
+	if err != nil {
+		cp.err = err
+		return
+	}
+	cp.w.Write(line)
+	cp.w.WriteByte('\n')
+	if flush {
+		cp.err = cp.w.Flush()
+		cp.flushed = time.Now()
+	}
+}
+
+// close flushes the journal and returns the first write error
+// as ErrCheckpointWrite.
+func (cp *checkpoint) close() error {
+	if cp == nil {
+		return nil
+	}
+	if cp.err == nil {
+		cp.err = cp.w.Flush()
+	}
+	if err := cp.f.Close(); cp.err == nil {
+		cp.err = err
+	}
+	if cp.err != nil {
+		return fmt.Errorf("%w: %w", ErrCheckpointWrite, cp.err)
+	}
+	return nil
+}
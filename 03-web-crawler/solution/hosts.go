@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// hostPacer keeps fetches from the same host apart.
+type hostPacer struct {
+	// next is the earliest time of the next fetch per host
+	next map[string]time.Time
+	mu   sync.Mutex
+}
+
+func newHostPacer() *hostPacer {
+	return &hostPacer{next: make(map[string]time.Time)}
+}
+
+// reserve takes the slot of host if the last fetch from it is at least
+// interval ago. Otherwise returns the time when the slot is free.
+func (p *hostPacer) reserve(host string, interval time.Duration) (time.Time, bool) {
+	if interval <= 0 {
+		return time.Time{}, true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if at := p.next[host]; now.Before(at) {
+		return at, false
+	}
+	p.next[host] = now.Add(interval)
+	return time.Time{}, true
+}
+
+// wait blocks until the last fetch from host is at least interval ago
+// and reserves the slot. Returns ctx.Err() if ctx is done first.
+func (p *hostPacer) wait(ctx context.Context, host string, interval time.Duration) error {
+	// Sleep without the lock, so other hosts don't wait for us.
+	// Another caller can take the slot while we sleep, so check again
+	for {
+		at, ok := p.reserve(host, interval)
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// hostBusyError is returned instead of waiting for a paced host,
+// if ctx is marked by deferPacing. The fetch can be tried again at until.
+type hostBusyError struct {
+	host  string
+	until time.Time
+}
+
+func (e *hostBusyError) Error() string {
+	return fmt.Sprintf("%s is paced until %s", e.host, e.until.Format(time.StampMilli))
+}
+
+type deferPacingKey struct{}
+
+// deferPacing marks ctx, so fetchers return *hostBusyError for a paced host
+// instead of waiting. The crawler uses it to fetch other hosts meanwhile.
+func deferPacing(ctx context.Context, deferred bool) context.Context {
+	return context.WithValue(ctx, deferPacingKey{}, deferred)
+}
+
+// pace reserves the slot of host, waiting for it or returning
+// *hostBusyError if ctx is marked by deferPacing.
+func (p *hostPacer) pace(ctx context.Context, host string, interval time.Duration) error {
+	if deferred, _ := ctx.Value(deferPacingKey{}).(bool); !deferred {
+		return p.wait(ctx, host, interval)
+	}
+	if at, ok := p.reserve(host, interval); !ok {
+		return &hostBusyError{host: host, until: at}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const DefaultMaxBodySize = 1 << 20
+
+var (
+	ErrBodyTooLarge = errors.New("response body too large")
+	ErrContentType  = errors.New("content type not allowed")
+)
+
+// StatusError is returned for responses with a non-2xx status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Temporary reports whether the request can succeed later:
+// on timeouts, rate limits and server errors.
+func (e *StatusError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= 500 && e.StatusCode != http.StatusNotImplemented
+}
+
+// HTTPFetcher fetches pages with net/http and extracts `<a href>` links from HTML.
+// Links are resolved against the page URL and normalized, fragments are dropped.
+type HTTPFetcher struct {
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// MaxBodySize is the maximum size of a response body in bytes.
+	// Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+	// ContentTypes are allowed media types. Defaults to text/html only.
+	// Links are extracted only from text/html pages.
+	ContentTypes []string
+	// SameDomain keeps only links to the same host as the fetched page.
+	SameDomain bool
+}
+
+// Fetch implements Fetcher.
+func (f *HTTPFetcher) Fetch(url string) (string, []string, error) {
+	return f.FetchContext(context.Background(), url)
+}
+
+// FetchContext is Fetch that can be cancelled.
+// Use ContextFetcherFunc(f.FetchContext) as a ContextFetcher.
+func (f *HTTPFetcher) FetchContext(ctx context.Context, rawURL string) (string, []string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", nil, &StatusError{URL: rawURL, StatusCode: resp.StatusCode}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	allowed := f.ContentTypes
+	if len(allowed) == 0 {
+		allowed = []string{"text/html"}
+	}
+	if !slices.Contains(allowed, mediaType) {
+		return "", nil, fmt.Errorf("%s: %w: %q", rawURL, ErrContentType, mediaType)
+	}
+
+	limit := f.MaxBodySize
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+	// Read one byte more to know if the body is over the limit
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if int64(len(body)) > limit {
+		return "", nil, fmt.Errorf("%s: %w", rawURL, ErrBodyTooLarge)
+	}
+
+	if mediaType != "text/html" {
+		return string(body), nil, nil
+	}
+	// Request URL changes on redirects, links are relative to the final one
+	links := extractLinks(resp.Request.URL, body)
+	if f.SameDomain {
+		links = slices.DeleteFunc(links, func(link string) bool {
+			u, err := url.Parse(link)
+			return err != nil || !strings.EqualFold(u.Hostname(), resp.Request.URL.Hostname())
+		})
+	}
+	return string(body), links, nil
+}
+
+// ContextFetcherFunc adapts a function to ContextFetcher.
+type ContextFetcherFunc func(ctx context.Context, url string) (string, []string, error)
+
+func (f ContextFetcherFunc) Fetch(ctx context.Context, url string) (string, []string, error) {
+	return f(ctx, url)
+}
+
+// extractLinks returns unique normalized http(s) links in document order.
+func extractLinks(base *url.URL, body []byte) []string {
+	var links []string
+	seen := make(map[string]bool)
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		tok := z.Token()
+		if tok.DataAtom != atom.A && tok.DataAtom != atom.Base {
+			continue
+		}
+		for _, attr := range tok.Attr {
+			if attr.Key != "href" {
+				continue
+			}
+			u, err := base.Parse(strings.TrimSpace(attr.Val))
+			if err != nil {
+				continue
+			}
+			// <base href> changes how the following links are resolved
+			if tok.DataAtom == atom.Base {
+				base = u
+				continue
+			}
+			if link, ok := normalizeURL(u); ok && !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+			}
+		}
+	}
+}
+
+// normalizeURL lowercases scheme and host, drops default ports and the fragment.
+// Returns false for non-http(s) URLs.
+func normalizeURL(u *url.URL) (string, bool) {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	if n.Scheme != "http" && n.Scheme != "https" {
+		return "", false
+	}
+	host, port := strings.ToLower(n.Hostname()), n.Port()
+	if (n.Scheme == "http" && port == "80") || (n.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		n.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		n.Host = "[" + host + "]"
+	default:
+		n.Host = host
+	}
+	if n.Path == "" {
+		n.Path = "/"
+	}
+	n.Fragment, n.RawFragment = "", ""
+	return n.String(), true
+}
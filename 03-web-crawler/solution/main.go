@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: crawler [flags] URL
+
+Crawls pages starting with URL and writes them as sitemap.xml, JSON or DOT graph.
+
+Flags:
+`
+
+var formats = map[string]func(io.Writer, *Report) error{
+	"sitemap": WriteSitemap,
+	"json":    WriteJSON,
+	"dot":     WriteDOT,
+}
+
+// patterns collects a repeated glob flag.
+type patterns []Pattern
+
+func (p *patterns) String() string {
+	return ""
+}
+
+func (p *patterns) Set(glob string) error {
+	*p = append(*p, Glob(glob))
+	return nil
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "crawler:", err)
+		os.Exit(1)
+	}
+}
+
+// run is the whole command, so it can be tested without a process.
+// A crawl stopped by ctx or a failed checkpoint still writes pages fetched so far.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("crawler", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	var include, exclude patterns
+	depth := flags.Int("depth", 3, "maximum number of links from the start URL")
+	workers := flags.Int("workers", DefaultWorkers, "number of fetches in flight")
+	hostWorkers := flags.Int("host-workers", 2, "number of fetches in flight to the same host, 0 means no limit")
+	scope := flags.String("scope", "host", "links to follow: host (same host), prefix (under the start URL) or all")
+	flags.Var(&include, "include", "follow only URLs matching the glob, can be repeated")
+	flags.Var(&exclude, "exclude", "don't follow URLs matching the glob, can be repeated")
+	robots := flags.Bool("robots", true, "respect robots.txt")
+	userAgent := flags.String("user-agent", DefaultUserAgent, "User-Agent header and robots.txt group")
+	attempts := flags.Int("attempts", DefaultMaxAttempts, "maximum number of fetches of a URL, only transient errors are retried")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of a single fetch")
+	checkpoint := flags.String("checkpoint", "", "file to resume the crawl from")
+	format := flags.String("format", "sitemap", "output format: sitemap, json or dot")
+	output := flags.String("o", "", "output file, standard output by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a single start URL")
+	}
+	u, err := url.Parse(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid start URL: %w", err)
+	}
+	start, ok := normalizeURL(u)
+	if !ok {
+		return fmt.Errorf("invalid start URL %q: only http and https are supported", flags.Arg(0))
+	}
+
+	write, ok := formats[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	client := &http.Client{
+		Timeout:   *timeout,
+		Transport: userAgentTransport{agent: *userAgent, next: http.DefaultTransport},
+	}
+	// Scope is not a filter rule, so -include can only narrow it
+	var inScope func(link string) bool
+	switch *scope {
+	case "host":
+		host := hostOf(start)
+		inScope = func(link string) bool { return hostOf(link) == host }
+	case "prefix":
+		inScope = underPrefix(start)
+	case "all":
+	default:
+		return fmt.Errorf("unknown scope %q", *scope)
+	}
+
+	fetcher := &HTTPFetcher{Client: client}
+	filter := &Filter{Include: include, Exclude: exclude, Robots: *robots, UserAgent: *userAgent, Client: client}
+	// Filter is inside of retries, so every attempt is paced by Crawl-delay
+	retry := &RetryPolicy{MaxAttempts: *attempts, Jitter: 0.5}
+	var next ContextFetcher = retry.Wrap(filter.Wrap(ContextFetcherFunc(fetcher.FetchContext)))
+	if inScope != nil {
+		next = scopeFetcher{next: next, inScope: inScope}
+	}
+	c := NewContextCrawler(next, Options{
+		Workers:     *workers,
+		HostWorkers: *hostWorkers,
+		Checkpoint:  *checkpoint,
+	})
+	report, crawlErr := c.CrawlReport(ctx, start, *depth)
+	if crawlErr != nil && ctx.Err() == nil && !errors.Is(crawlErr, ErrCheckpointWrite) {
+		return crawlErr
+	}
+
+	if *output == "" {
+		err = write(stdout, report)
+	} else {
+		err = writeFile(*output, report, write)
+	}
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, p := range report.Pages {
+		if p.Err != nil {
+			failed++
+		}
+	}
+	fmt.Fprintf(stderr, "crawled %d pages, %d failed\n", len(report.Pages), failed)
+	return crawlErr
+}
+
+func writeFile(name string, report *Report, write func(io.Writer, *Report) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// scopeFetcher drops links out of the crawl scope, so they are
+// neither fetched nor reported as failed.
+type scopeFetcher struct {
+	next    ContextFetcher
+	inScope func(link string) bool
+}
+
+func (f scopeFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	body, links, err := f.next.Fetch(ctx, url)
+	var scoped []string
+	for _, link := range links {
+		if f.inScope(link) {
+			scoped = append(scoped, link)
+		}
+	}
+	return body, scoped, err
+}
+
+// underPrefix reports whether link is start or under its path.
+// Whole path segments are compared, so /docs-old is not under /docs.
+func underPrefix(start string) func(link string) bool {
+	base, err := url.Parse(start)
+	if err != nil {
+		return func(string) bool { return false }
+	}
+	dir := strings.TrimSuffix(base.Path, "/") + "/"
+	return func(link string) bool {
+		u, err := url.Parse(link)
+		if err != nil || u.Scheme != base.Scheme || u.Host != base.Host {
+			return false
+		}
+		return u.Path == base.Path || strings.HasPrefix(u.Path, dir)
+	}
+}
+
+// userAgentTransport sets User-Agent header of every request.
+type userAgentTransport struct {
+	agent string
+	next  http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.agent)
+	return t.next.RoundTrip(req)
+}
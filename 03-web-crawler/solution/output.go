@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemap struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// WriteSitemap writes successfully fetched pages as sitemap.xml.
+func WriteSitemap(w io.Writer, report *Report) error {
+	var sm sitemap
+	for _, p := range report.Pages {
+		if p.Err == nil {
+			sm.URLs = append(sm.URLs, sitemapURL{Loc: p.URL})
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(sm); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type jsonPage struct {
+	URL    string   `json:"url"`
+	Depth  int      `json:"depth"`
+	Parent string   `json:"parent,omitempty"`
+	Links  []string `json:"links,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// WriteJSON writes every page with its links and error, without bodies.
+func WriteJSON(w io.Writer, report *Report) error {
+	pages := make([]jsonPage, len(report.Pages))
+	for i, p := range report.Pages {
+		pages[i] = jsonPage{URL: p.URL, Depth: p.Depth, Parent: p.Parent, Links: p.Links}
+		if p.Err != nil {
+			pages[i].Error = p.Err.Error()
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	// It's not embedded into HTML, so URLs stay readable
+	enc.SetEscapeHTML(false)
+	return enc.Encode(struct {
+		Pages []jsonPage `json:"pages"`
+	}{pages})
+}
+
+// WriteDOT writes the link graph in Graphviz DOT format.
+// Only links between crawled pages are drawn, failed pages are red.
+func WriteDOT(w io.Writer, report *Report) error {
+	crawled := make(map[string]bool, len(report.Pages))
+	for _, p := range report.Pages {
+		crawled[p.URL] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph crawl {\n")
+	for _, p := range report.Pages {
+		if p.Err != nil {
+			fmt.Fprintf(&b, "  %s [color=red];\n", dotQuote(p.URL))
+			continue
+		}
+		fmt.Fprintf(&b, "  %s;\n", dotQuote(p.URL))
+	}
+	// Pages are iterated instead of the graph, so the output is stable
+	for _, p := range report.Pages {
+		for _, link := range report.Graph[p.URL] {
+			if crawled[link] {
+				fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(p.URL), dotQuote(link))
+			}
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote returns s as a DOT string, where only quotes need escaping.
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
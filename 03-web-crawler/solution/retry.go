@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 100 * time.Millisecond
+	DefaultMaxDelay    = 5 * time.Second
+)
+
+// RetryPolicy retries fetches that failed with a retryable error.
+type RetryPolicy struct {
+	// MaxAttempts is the number of fetches of a URL, including the first one.
+	// Defaults to DefaultMaxAttempts.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles for every next one.
+	// Defaults to DefaultBaseDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay. Defaults to DefaultMaxDelay.
+	MaxDelay time.Duration
+	// Jitter is the fraction of the delay, from 0 to 1, that is randomized,
+	// so clients that failed together don't retry together. Zero means no jitter.
+	Jitter float64
+	// Retryable classifies errors. Defaults to IsRetryable.
+	Retryable func(error) bool
+}
+
+// IsRetryable reports whether a fetch that failed with err can succeed later.
+// Timeouts, including http.Client.Timeout, and cut responses are retryable.
+// Other errors with `Temporary() bool` method, like *StatusError, decide
+// themselves. Anything else, including cancellation and not found pages, is permanent.
+func IsRetryable(err error) bool {
+	// Timeout of a single request is also context.DeadlineExceeded.
+	// Retries stop on the deadline of the crawl anyway, because ctx is done
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) {
+		return temporary.Temporary()
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Wrap returns a ContextFetcher that retries failed fetches with
+// exponential backoff. Permanent errors are returned right away.
+// After the last attempt the error says how many attempts were made.
+func (p *RetryPolicy) Wrap(fetcher ContextFetcher) ContextFetcher {
+	policy := *p
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultMaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = DefaultBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultMaxDelay
+	}
+	policy.Jitter = min(max(policy.Jitter, 0), 1)
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+	return &retryFetcher{RetryPolicy: policy, fetcher: fetcher}
+}
+
+type retryFetcher struct {
+	RetryPolicy
+	fetcher ContextFetcher
+}
+
+func (f *retryFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	for attempt := 1; ; attempt++ {
+		body, urls, err := f.fetcher.Fetch(ctx, url)
+		var busy *hostBusyError
+		if err == nil || errors.As(err, &busy) || !f.Retryable(err) || ctx.Err() != nil {
+			return body, urls, err
+		}
+		if attempt == 1 {
+			// A busy host is handed back to the crawler only on the first attempt,
+			// otherwise the attempts would start over every time. Retries wait for it
+			ctx = deferPacing(ctx, false)
+		}
+		if attempt == f.MaxAttempts {
+			return "", nil, fmt.Errorf("after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(f.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return "", nil, ctx.Err()
+		}
+	}
+}
+
+// delay returns backoff before the retry after attempt.
+func (f *retryFetcher) delay(attempt int) time.Duration {
+	d := f.MaxDelay
+	// MaxDelay is shifted back instead, so the delay can't overflow
+	if shift := attempt - 1; shift < 63 && f.BaseDelay <= f.MaxDelay>>shift {
+		d = f.BaseDelay << shift
+	}
+	return d - time.Duration(f.Jitter*rand.Float64()*float64(d))
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultUserAgent = "gocrawler"
+
+var (
+	ErrExcluded   = errors.New("excluded by URL rules")
+	ErrDisallowed = errors.New("disallowed by robots.txt")
+)
+
+// Pattern matches URLs. *regexp.Regexp is a Pattern.
+type Pattern interface {
+	MatchString(s string) bool
+}
+
+// Glob returns a Pattern matching the whole URL, where
+// `*` matches any sequence of characters and `?` matches a single one.
+func Glob(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Filter decides which URLs can be fetched.
+type Filter struct {
+	// Include, if not empty, allows only URLs matching one of the patterns.
+	Include []Pattern
+	// Exclude rejects URLs matching any of the patterns. It wins over Include.
+	Exclude []Pattern
+	// Robots enables robots.txt rules. Crawl-delay keeps fetches from a host apart,
+	// a Crawler fetches other hosts meanwhile.
+	Robots bool
+	// UserAgent selects the robots.txt group. Defaults to DefaultUserAgent.
+	UserAgent string
+	// Client fetches robots.txt. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Wrap returns a ContextFetcher that checks the rules before anything
+// is fetched. Rejected URLs fail with ErrExcluded or ErrDisallowed.
+func (f *Filter) Wrap(fetcher ContextFetcher) ContextFetcher {
+	return &filterFetcher{
+		Filter:  *f,
+		fetcher: fetcher,
+		robots:  make(map[string]*robotsEntry),
+		pacer:   newHostPacer(),
+	}
+}
+
+type robotsEntry struct {
+	rules *robotsRules
+	ready chan struct{}
+}
+
+type filterFetcher struct {
+	Filter
+	fetcher ContextFetcher
+	// robots.txt rules per scheme and host
+	robots map[string]*robotsEntry
+	pacer  *hostPacer
+	mu     sync.Mutex
+}
+
+func (f *filterFetcher) Fetch(ctx context.Context, rawURL string) (string, []string, error) {
+	if !f.match(rawURL) {
+		return "", nil, fmt.Errorf("%s: %w", rawURL, ErrExcluded)
+	}
+
+	if f.Robots {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", nil, err
+		}
+		rules, err := f.robotsRules(ctx, u)
+		if err != nil {
+			return "", nil, err
+		}
+		if !rules.allowed(u.RequestURI()) {
+			return "", nil, fmt.Errorf("%s: %w", rawURL, ErrDisallowed)
+		}
+		if err := f.pacer.pace(ctx, u.Host, rules.delay); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return f.fetcher.Fetch(ctx, rawURL)
+}
+
+func (f *filterFetcher) match(rawURL string) bool {
+	for _, p := range f.Exclude {
+		if p.MatchString(rawURL) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, p := range f.Include {
+		if p.MatchString(rawURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// robotsRules fetches robots.txt of the host once,
+// others wait for the first caller, the same way as in a non-blocking cache.
+func (f *filterFetcher) robotsRules(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	key := u.Scheme + "://" + u.Host
+
+	for {
+		f.mu.Lock()
+		e := f.robots[key]
+		if e == nil {
+			e = &robotsEntry{ready: make(chan struct{})}
+			f.robots[key] = e
+			f.mu.Unlock()
+
+			rules := f.fetchRobots(ctx, key+"/robots.txt")
+			// Cancelled fetch tells nothing about the host,
+			// so the entry is dropped and waiters try again
+			if ctx.Err() != nil {
+				f.mu.Lock()
+				delete(f.robots, key)
+				f.mu.Unlock()
+				close(e.ready)
+				return nil, ctx.Err()
+			}
+			e.rules = rules
+			close(e.ready)
+			return rules, nil
+		}
+		f.mu.Unlock()
+
+		select {
+		case <-e.ready:
+			if e.rules != nil {
+				return e.rules, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// fetchRobots follows RFC 9309: a missing robots.txt allows everything,
+// an unreachable one disallows everything.
+func (f *filterFetcher) fetchRobots(ctx context.Context, robotsURL string) *robotsRules {
+	disallowAll := &robotsRules{rules: []robotsRule{{allow: false, pattern: regexp.MustCompile("^/"), length: 1}}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return disallowAll
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return disallowAll
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		agent := f.UserAgent
+		if agent == "" {
+			agent = DefaultUserAgent
+		}
+		// Like browsers, we don't read more than 500 KiB
+		return parseRobots(io.LimitReader(resp.Body, 500<<10), agent)
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		return &robotsRules{}
+	default:
+		return disallowAll
+	}
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern *regexp.Regexp
+	// length of the original path, the longest match wins
+	length int
+}
+
+type robotsRules struct {
+	rules []robotsRule
+	delay time.Duration
+}
+
+// allowed reports whether path, with the query, can be fetched.
+// The longest matching rule wins, Allow wins a tie.
+func (r *robotsRules) allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allow, length := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > length || (rule.length == length && rule.allow) {
+			allow, length = rule.allow, rule.length
+		}
+	}
+	return allow
+}
+
+// parseRobots returns rules of the group for agent,
+// or of the `*` group if there is none for agent.
+// Groups match the product token of agent, "gocrawler" of "GoCrawler/1.0",
+// as a whole and case-insensitively.
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	agent = productToken(agent)
+	var own, any robotsRules
+	var hasOwn bool
+
+	// Groups start with one or more User-agent lines
+	var current []*robotsRules
+	inAgents := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !inAgents {
+				current = nil
+				inAgents = true
+			}
+			switch name := strings.ToLower(value); {
+			case name == "*":
+				current = append(current, &any)
+			case name != "" && name == agent:
+				current = append(current, &own)
+				hasOwn = true
+			}
+			continue
+		}
+		inAgents = false
+
+		for _, group := range current {
+			switch key {
+			case "allow", "disallow":
+				// Empty Disallow allows everything, so it's not a rule
+				if value == "" {
+					continue
+				}
+				group.rules = append(group.rules, robotsRule{
+					allow:   key == "allow",
+					pattern: robotsPattern(value),
+					length:  len(value),
+				})
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					group.delay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if hasOwn {
+		return &own
+	}
+	return &any
+}
+
+// productToken returns the lower-case name of a User-Agent before its
+// version and comments.
+func productToken(agent string) string {
+	if i := strings.IndexAny(agent, "/ "); i >= 0 {
+		agent = agent[:i]
+	}
+	return strings.ToLower(agent)
+}
+
+// robotsPattern converts a robots.txt path, where `*` matches any sequence
+// and `$` matches the end of the path, to a regular expression.
+func robotsPattern(path string) *regexp.Regexp {
+	end := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+	parts := strings.Split(path, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if end {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
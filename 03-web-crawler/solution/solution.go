@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
 	"sync"
+	"time"
 )
 
 type Fetcher interface {
@@ -10,55 +15,440 @@ type Fetcher interface {
 	Fetch(url string) (body string, urls []string, err error)
 }
 
-var (
-	m  = map[string]bool{}
-	mu sync.RWMutex
-)
+// ContextFetcher is a Fetcher that can be cancelled.
+type ContextFetcher interface {
+	// Fetch returns the body of URL and
+	// a slice of URLs found on that page.
+	Fetch(ctx context.Context, url string) (body string, urls []string, err error)
+}
+
+// WithContext adapts Fetcher to ContextFetcher.
+// A fetch in flight can't be cancelled, but a new one
+// doesn't start if ctx is already done.
+func WithContext(fetcher Fetcher) ContextFetcher {
+	return contextFetcher{fetcher}
+}
+
+type contextFetcher struct {
+	Fetcher
+}
+
+func (f contextFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	return f.Fetcher.Fetch(url)
+}
+
+const DefaultWorkers = 10
+
+// Options configures a Crawler.
+type Options struct {
+	// Workers is the number of fetches in flight during a crawl.
+	// Defaults to DefaultWorkers.
+	Workers int
+	// HostWorkers limits fetches in flight to the same host during a crawl.
+	// Zero means no limit.
+	HostWorkers int
+	// HostInterval is the minimum time between two fetches from the same host.
+	// Zero means no limit.
+	HostInterval time.Duration
+	// Visited remembers visited URLs. Defaults to a MapSet.
+	Visited VisitedSet
+	// Checkpoint is a file, where CrawlReport keeps the frontier and fetched pages
+	// as JSON lines. A crawl with the same URL and depth continues from it
+	// without fetching written pages again. Empty means no checkpoint.
+	Checkpoint string
+	// CheckpointInterval is how often fetched pages are written to Checkpoint,
+	// the ones not written yet are fetched again on restart.
+	// Zero means after every page.
+	CheckpointInterval time.Duration
+}
+
+// Crawler owns its visited set and results,
+// so several crawls can run in the same process.
+type Crawler struct {
+	fetcher ContextFetcher
+	opts    Options
+	visited VisitedSet
+	pacer   *hostPacer
+	results []string
+	mu      sync.Mutex
+}
+
+func NewCrawler(fetcher Fetcher, opts Options) *Crawler {
+	return NewContextCrawler(WithContext(fetcher), opts)
+}
+
+func NewContextCrawler(fetcher ContextFetcher, opts Options) *Crawler {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Visited == nil {
+		opts.Visited = NewMapSet()
+	}
+	return &Crawler{
+		fetcher: fetcher,
+		opts:    opts,
+		visited: opts.Visited,
+		pacer:   newHostPacer(),
+	}
+}
 
 // Crawl uses fetcher to recursively crawl
 // pages starting with url, to a maximum of depth.
 func Crawl(url string, depth int, fetcher Fetcher) ([]string, error) {
-	if depth <= 0 {
-		return nil, nil
+	return NewCrawler(fetcher, Options{}).Crawl(url, depth)
+}
+
+// CrawlContext is Crawl that stops when ctx is done.
+// See Crawler.CrawlContext.
+func CrawlContext(ctx context.Context, url string, depth int, fetcher ContextFetcher) ([]string, error) {
+	return NewContextCrawler(fetcher, Options{}).CrawlContext(ctx, url, depth)
+}
+
+// Page is the result of fetching a single URL.
+type Page struct {
+	URL string
+	// Depth is the number of links from the start URL.
+	Depth int
+	// Parent is URL of the page where this one was found first.
+	// Empty for the start URL.
+	Parent string
+	Body   string
+	Links  []string
+	Err    error
+}
+
+// Report is the result of a crawl.
+type Report struct {
+	// Pages are in BFS order: by depth, then by parent, then by link order.
+	Pages []Page
+	// Graph maps every successfully fetched URL to URLs found on it.
+	Graph map[string][]string
+}
+
+type task struct {
+	url  string
+	host string
+	// requeued is true if the host was busy at the last try.
+	// The URL is already marked as visited
+	requeued bool
+}
+
+type fetchResult struct {
+	task
+	body string
+	urls []string
+	err  error
+	// visited is true if the URL was visited by another crawl, so it's not fetched
+	visited bool
+}
+
+// node is a URL found by a crawl.
+type node struct {
+	task
+	parent string
+	depth  int
+	// key is the path of link indexes from the start URL.
+	// Pages are reported in the order of keys, the shorter first
+	key []int
+	// res is nil until the page is fetched
+	res *fetchResult
+}
+
+// less reports whether key a goes before b in BFS order.
+func less(a, b []int) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
 	}
-	mu.RLock()
-	if m[url] {
-		mu.RUnlock()
-		return nil, nil
+	return slices.Compare(a, b) < 0
+}
+
+// Crawl recursively crawls pages starting with url, to a maximum of depth.
+// Pages visited by previous calls are not fetched again.
+// Returns bodies of pages fetched by this call.
+func (c *Crawler) Crawl(url string, depth int) ([]string, error) {
+	return c.CrawlContext(context.Background(), url, depth)
+}
+
+// CrawlContext is Crawl that stops when ctx is done. No new fetches
+// are started after that, but fetches in flight are waited for.
+// Returns bodies fetched so far together with ctx.Err().
+// If Options.Checkpoint can't be written, the crawl goes on without it
+// and ErrCheckpointWrite is returned with all bodies.
+// URLs are marked as visited only when a worker takes them,
+// so pages found but not fetched yet are fetched by a later crawl.
+func (c *Crawler) CrawlContext(ctx context.Context, url string, depth int) ([]string, error) {
+	// Only the start URL can fail before anything is fetched,
+	// so bodies are returned with any error
+	report, err := c.CrawlReport(ctx, url, depth)
+	var bodies []string
+	for _, p := range report.Pages {
+		if p.Err == nil {
+			bodies = append(bodies, p.Body)
+		}
 	}
-	mu.RUnlock()
+	return bodies, err
+}
 
-	mu.Lock()
-	m[url] = true
-	mu.Unlock()
+// CrawlReport is CrawlContext that reports every page, including failed ones.
+// Returns an error if ctx is done, the start URL can't be fetched
+// or the checkpoint can't be written. The report is returned in every case.
+// Errors of pages restored from a checkpoint keep only their text.
+func (c *Crawler) CrawlReport(ctx context.Context, url string, depth int) (_ *Report, err error) {
+	if depth <= 0 {
+		return &Report{Graph: make(map[string][]string)}, nil
+	}
 
-	body, urls, err := fetcher.Fetch(url)
-	if err != nil {
-		return nil, err
+	cr := &crawl{Crawler: c, ctx: ctx, depth: depth, nodes: make(map[string]*node), busy: make(map[string]time.Time)}
+	if c.opts.Checkpoint != "" {
+		if cr.cp, err = openCheckpoint(c.opts.Checkpoint, url, depth, c.opts.CheckpointInterval); err != nil {
+			return &Report{Graph: make(map[string][]string)}, err
+		}
+		defer func() {
+			if cerr := cr.cp.close(); err == nil {
+				err = cerr
+			}
+		}()
 	}
-	result := []string{body}
 
-	ch := make(chan string)
+	// Fixed number of workers fetch pages. This goroutine owns the frontier
+	// and hands tasks to workers, so workers never block each other
+	tasks, results := make(chan task), make(chan fetchResult)
+	// Workers don't wait for hosts paced by the fetcher, like by Crawl-delay,
+	// the tasks are requeued instead
+	fetchCtx := deferPacing(ctx, true)
 	var wg sync.WaitGroup
-	for _, u := range urls {
+	for i := 0; i < c.opts.Workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if res, err := Crawl(u, depth-1, fetcher); err == nil {
-				for _, b := range res {
-					ch <- b
+			for t := range tasks {
+				res := fetchResult{task: t}
+				// Tasks taken after ctx is done are not marked, a later crawl fetches them
+				switch {
+				case ctx.Err() != nil:
+					res.err = ctx.Err()
+				case !t.requeued && !c.visited.Visit(t.url):
+					res.visited = true
+				default:
+					res.body, res.urls, res.err = c.fetcher.Fetch(fetchCtx, t.url)
 				}
+				results <- res
 			}
 		}()
 	}
-	go func() {
-		wg.Wait()
-		close(ch)
-	}()
+	defer wg.Wait()
+	defer close(tasks)
+
+	cr.found(&node{task: task{url: url, host: hostOf(url)}})
+	cr.run(tasks, results)
+
+	// Pages are fetched in any order, but reported in the order of keys,
+	// so the report doesn't depend on timing
+	report := cr.report()
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	if len(report.Pages) > 0 && report.Pages[0].Err != nil {
+		return report, report.Pages[0].Err
+	}
+	return report, nil
+}
+
+// crawl is the state of a single CrawlReport.
+// Only the goroutine running the crawl uses it, so there is no lock.
+type crawl struct {
+	*Crawler
+	ctx   context.Context
+	depth int
+	cp    *checkpoint
+	// nodes are URLs found by this crawl, the visited set is shared with others
+	nodes map[string]*node
+	// pending are nodes waiting for a worker
+	pending []*node
+	// busy are hosts paced by the fetcher, until they are ready
+	busy map[string]time.Time
+}
+
+// run hands pending nodes to workers until there are none left and
+// every fetch is finished. When ctx is done, no new fetches are started,
+// but fetches in flight are waited for.
+func (cr *crawl) run(tasks chan<- task, results <-chan fetchResult) {
+	hostInFlight := make(map[string]int)
+	var inFlight int
+	// ready is a task whose host slot is already reserved, it waits for a worker
+	var ready *task
+	done := cr.ctx.Done()
+	for len(cr.pending) > 0 || ready != nil || inFlight > 0 {
+		// Nil channels block forever, so the cases are disabled
+		// when there is nothing we are allowed to start
+		var send chan<- task
+		var wake <-chan time.Time
+		if ready == nil {
+			next, at := cr.nextTask(hostInFlight)
+			switch {
+			case next >= 0:
+				ready = &cr.pending[next].task
+				cr.pending = slices.Delete(cr.pending, next, next+1)
+			case !at.IsZero():
+				// Workers don't sleep for a host, so other hosts are fetched meanwhile
+				wake = time.After(time.Until(at))
+			}
+		}
+		var t task
+		if ready != nil {
+			send, t = tasks, *ready
+		}
+
+		select {
+		case send <- t:
+			hostInFlight[t.host]++
+			inFlight++
+			ready = nil
+		case <-wake:
+			// A paced host is ready, the next task is chosen again
+		case res := <-results:
+			hostInFlight[res.host]--
+			inFlight--
+			// Fetch was cancelled, so it's the same as not started
+			if res.visited || res.err != nil && cr.ctx.Err() != nil && errors.Is(res.err, cr.ctx.Err()) {
+				continue
+			}
+			var busy *hostBusyError
+			if errors.As(res.err, &busy) {
+				cr.busy[busy.host] = busy.until
+				if n := cr.nodes[res.url]; cr.ctx.Err() == nil {
+					n.requeued = true
+					cr.pending = append(cr.pending, n)
+				}
+				continue
+			}
+			cr.cp.writePage(&res)
+			cr.fetched(&res)
+		case <-done:
+			// Stop scheduling, but keep receiving results of fetches in flight.
+			// Done channel stays closed, so we stop selecting it
+			cr.pending, ready, done = nil, nil, nil
+		}
+	}
+}
 
-	for b := range ch {
-		result = append(result, b)
+// nextTask returns index of the first pending node, whose host has
+// a free slot and is not paced by HostInterval or the fetcher, and reserves the host.
+// Otherwise it returns -1 and the earliest time a paced host is ready,
+// or zero time if no host is paced.
+func (cr *crawl) nextTask(hostInFlight map[string]int) (int, time.Time) {
+	var wake time.Time
+	paced := make(map[string]bool)
+	for i, n := range cr.pending {
+		if cr.opts.HostWorkers > 0 && hostInFlight[n.host] >= cr.opts.HostWorkers || paced[n.host] {
+			continue
+		}
+		at, ok := cr.busy[n.host], false
+		if time.Now().After(at) {
+			if at, ok = cr.pacer.reserve(n.host, cr.opts.HostInterval); ok {
+				return i, time.Time{}
+			}
+		}
+		paced[n.host] = true
+		if wake.IsZero() || at.Before(wake) {
+			wake = at
+		}
 	}
+	return -1, wake
+}
 
-	return result, nil
+// found adds a new node. A page in the checkpoint is not fetched again.
+func (cr *crawl) found(n *node) {
+	cr.nodes[n.url] = n
+	if res := cr.cp.page(n.task); res != nil {
+		// Restored page was taken by a worker before the restart
+		cr.visited.Visit(n.url)
+		cr.fetched(res)
+		return
+	}
+	if cr.ctx.Err() == nil {
+		cr.pending = append(cr.pending, n)
+	}
+}
+
+// fetched records the result of a node and follows its links.
+func (cr *crawl) fetched(res *fetchResult) {
+	n := cr.nodes[res.url]
+	n.res = res
+	if res.err != nil {
+		return
+	}
+	cr.mu.Lock()
+	cr.results = append(cr.results, res.body)
+	cr.mu.Unlock()
+	cr.follow(n)
+}
+
+// follow finds links of a fetched node. Pages are fetched in any order,
+// so a link can be found again with a smaller key. Then its parent,
+// depth and key are updated and its own links are followed again,
+// so every page ends up with the same key as in a level by level crawl.
+func (cr *crawl) follow(n *node) {
+	if n.depth+1 >= cr.depth {
+		return
+	}
+	for i, u := range n.res.urls {
+		key := append(slices.Clip(n.key), i)
+		m := cr.nodes[u]
+		switch {
+		case m == nil:
+			cr.found(&node{task: task{url: u, host: hostOf(u)}, parent: n.url, depth: n.depth + 1, key: key})
+		case less(key, m.key):
+			m.parent, m.depth, m.key = n.url, n.depth+1, key
+			if m.res != nil && m.res.err == nil {
+				cr.follow(m)
+			}
+		}
+	}
+}
+
+// report returns fetched pages in BFS order.
+func (cr *crawl) report() *Report {
+	var nodes []*node
+	for _, n := range cr.nodes {
+		if n.res != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	slices.SortFunc(nodes, func(a, b *node) int {
+		switch {
+		case less(a.key, b.key):
+			return -1
+		case less(b.key, a.key):
+			return 1
+		}
+		return 0
+	})
+
+	report := &Report{Pages: make([]Page, len(nodes)), Graph: make(map[string][]string)}
+	for i, n := range nodes {
+		report.Pages[i] = Page{URL: n.url, Depth: n.depth, Parent: n.parent, Body: n.res.body, Links: n.res.urls, Err: n.res.err}
+		if n.res.err == nil {
+			report.Graph[n.url] = n.res.urls
+		}
+	}
+	return report
+}
+
+// Results returns bodies of all pages fetched by the crawler.
+func (c *Crawler) Results() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.results...)
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// VisitedSet remembers visited URLs.
+type VisitedSet interface {
+	// Visit marks url as visited. Returns false if it was visited before.
+	// Check and mark happen as one step, so only one caller gets true.
+	Visit(url string) bool
+}
+
+// MapSet is a VisitedSet protected by a single mutex.
+type MapSet struct {
+	m  map[string]struct{}
+	mu sync.Mutex
+}
+
+func NewMapSet() *MapSet {
+	return &MapSet{m: make(map[string]struct{})}
+}
+
+func (s *MapSet) Visit(url string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.m[url]; ok {
+		return false
+	}
+	s.m[url] = struct{}{}
+	return true
+}
+
+// ShardedSet spreads URLs across several MapSets,
+// so goroutines visiting different URLs rarely wait for each other.
+type ShardedSet struct {
+	shards []*MapSet
+}
+
+func NewShardedSet(shards int) *ShardedSet {
+	s := &ShardedSet{shards: make([]*MapSet, max(shards, 1))}
+	for i := range s.shards {
+		s.shards[i] = NewMapSet()
+	}
+	return s
+}
+
+func (s *ShardedSet) Visit(url string) bool {
+	h := fnv.New32a()
+	h.Write([]byte(url))
+	return s.shards[h.Sum32()%uint32(len(s.shards))].Visit(url)
+}
+
+// BloomSet is a VisitedSet with fixed memory for huge crawls.
+// False positives are possible: a new URL can be reported as visited
+// and skipped. A URL is never reported as new twice.
+type BloomSet struct {
+	bits   []uint64
+	hashes int
+	mu     sync.Mutex
+}
+
+// NewBloomSet creates a set sized for n URLs
+// with the false positive probability p.
+func NewBloomSet(n int, p float64) *BloomSet {
+	n = max(n, 1)
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	// Optimal number of bits and hash functions
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := max(int(math.Round(m/float64(n)*math.Ln2)), 1)
+	return &BloomSet{bits: make([]uint64, int(m)/64+1), hashes: k}
+}
+
+func (s *BloomSet) Visit(url string) bool {
+	// Double hashing: i-th hash is h1 + i*h2
+	h := fnv.New64a()
+	h.Write([]byte(url))
+	sum := h.Sum64()
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+	size := uint64(len(s.bits)) * 64
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	visited := true
+	for i := 0; i < s.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if s.bits[word]&mask == 0 {
+			visited = false
+			s.bits[word] |= mask
+		}
+	}
+	return !visited
+}
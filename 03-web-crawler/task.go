@@ -1,11 +1,36 @@
 package main
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
 type Fetcher interface {
 	// Fetch returns the body of URL and
 	// a slice of URLs found on that page.
 	Fetch(url string) (body string, urls []string, err error)
 }
 
+// ContextFetcher is a Fetcher that can be cancelled.
+type ContextFetcher interface {
+	// Fetch returns the body of URL and
+	// a slice of URLs found on that page.
+	Fetch(ctx context.Context, url string) (body string, urls []string, err error)
+}
+
+// WithContext adapts Fetcher to ContextFetcher.
+// A fetch in flight can't be cancelled, but a new one
+// doesn't start if ctx is already done.
+func WithContext(fetcher Fetcher) ContextFetcher {
+	return nil
+}
+
 // Crawl uses fetcher to recursively crawl
 // pages starting with url, to a maximum of depth.
 func Crawl(url string, depth int, fetcher Fetcher) ([]string, error) {
@@ -27,3 +52,317 @@ func Crawl(url string, depth int, fetcher Fetcher) ([]string, error) {
 	}
 	return result, nil
 }
+
+const DefaultWorkers = 10
+
+// Options configures a Crawler.
+type Options struct {
+	// Workers is the number of fetches in flight during a crawl.
+	// Defaults to DefaultWorkers.
+	Workers int
+	// HostWorkers limits fetches in flight to the same host during a crawl.
+	// Zero means no limit.
+	HostWorkers int
+	// HostInterval is the minimum time between two fetches from the same host.
+	// Zero means no limit.
+	HostInterval time.Duration
+	// Visited remembers visited URLs. Defaults to a MapSet.
+	Visited VisitedSet
+	// Checkpoint is a file, where CrawlReport keeps the frontier and fetched pages
+	// as JSON lines. A crawl with the same URL and depth continues from it
+	// without fetching written pages again. Empty means no checkpoint.
+	Checkpoint string
+	// CheckpointInterval is how often fetched pages are written to Checkpoint,
+	// the ones not written yet are fetched again on restart.
+	// Zero means after every page.
+	CheckpointInterval time.Duration
+}
+
+var (
+	ErrCheckpointMismatch = errors.New("checkpoint belongs to another crawl")
+	ErrCheckpointWrite    = errors.New("checkpoint write failed")
+)
+
+// Crawler owns its visited set and results,
+// so several crawls can run in the same process.
+type Crawler struct {
+	fetcher ContextFetcher
+	opts    Options
+	mu      sync.Mutex
+	// You can add new fields if needed
+}
+
+func NewCrawler(fetcher Fetcher, opts Options) *Crawler {
+	return NewContextCrawler(WithContext(fetcher), opts)
+}
+
+func NewContextCrawler(fetcher ContextFetcher, opts Options) *Crawler {
+	return &Crawler{fetcher: fetcher, opts: opts}
+}
+
+// CrawlContext is Crawl that stops when ctx is done.
+// See Crawler.CrawlContext.
+func CrawlContext(ctx context.Context, url string, depth int, fetcher ContextFetcher) ([]string, error) {
+	return nil, nil
+}
+
+// Crawl recursively crawls pages starting with url, to a maximum of depth.
+// Pages visited by previous calls are not fetched again.
+// Returns bodies of pages fetched by this call.
+func (c *Crawler) Crawl(url string, depth int) ([]string, error) {
+	return c.CrawlContext(context.Background(), url, depth)
+}
+
+// Page is the result of fetching a single URL.
+type Page struct {
+	URL string
+	// Depth is the number of links from the start URL.
+	Depth int
+	// Parent is URL of the page where this one was found first.
+	// Empty for the start URL.
+	Parent string
+	Body   string
+	Links  []string
+	Err    error
+}
+
+// Report is the result of a crawl.
+type Report struct {
+	// Pages are in BFS order: by depth, then by parent, then by link order.
+	Pages []Page
+	// Graph maps every successfully fetched URL to URLs found on it.
+	Graph map[string][]string
+}
+
+// CrawlContext is Crawl that stops when ctx is done. No new fetches
+// are started after that, but fetches in flight are waited for.
+// Returns bodies fetched so far together with ctx.Err().
+// If Options.Checkpoint can't be written, the crawl goes on without it
+// and ErrCheckpointWrite is returned with all bodies.
+// URLs are marked as visited only when a worker takes them,
+// so pages found but not fetched yet are fetched by a later crawl.
+func (c *Crawler) CrawlContext(ctx context.Context, url string, depth int) ([]string, error) {
+	return nil, nil
+}
+
+// CrawlReport is CrawlContext that reports every page, including failed ones.
+// Returns an error if ctx is done, the start URL can't be fetched
+// or the checkpoint can't be written. The report is returned in every case.
+// Errors of pages restored from a checkpoint keep only their text.
+func (c *Crawler) CrawlReport(ctx context.Context, url string, depth int) (*Report, error) {
+	return &Report{}, nil
+}
+
+// Results returns bodies of all pages fetched by the crawler.
+func (c *Crawler) Results() []string {
+	return nil
+}
+
+// VisitedSet remembers visited URLs.
+type VisitedSet interface {
+	// Visit marks url as visited. Returns false if it was visited before.
+	// Check and mark happen as one step, so only one caller gets true.
+	Visit(url string) bool
+}
+
+// MapSet is a VisitedSet protected by a single mutex.
+type MapSet struct {
+	// You can add new fields if needed
+}
+
+func NewMapSet() *MapSet {
+	return &MapSet{}
+}
+
+func (s *MapSet) Visit(url string) bool {
+	return true
+}
+
+// ShardedSet spreads URLs across several MapSets,
+// so goroutines visiting different URLs rarely wait for each other.
+type ShardedSet struct {
+	// You can add new fields if needed
+}
+
+func NewShardedSet(shards int) *ShardedSet {
+	return &ShardedSet{}
+}
+
+func (s *ShardedSet) Visit(url string) bool {
+	return true
+}
+
+// BloomSet is a VisitedSet with fixed memory for huge crawls.
+// False positives are possible: a new URL can be reported as visited
+// and skipped. A URL is never reported as new twice.
+type BloomSet struct {
+	// You can add new fields if needed
+}
+
+// NewBloomSet creates a set sized for n URLs
+// with the false positive probability p.
+func NewBloomSet(n int, p float64) *BloomSet {
+	return &BloomSet{}
+}
+
+func (s *BloomSet) Visit(url string) bool {
+	return true
+}
+
+const DefaultMaxBodySize = 1 << 20
+
+var (
+	ErrBodyTooLarge = errors.New("response body too large")
+	ErrContentType  = errors.New("content type not allowed")
+)
+
+// StatusError is returned for responses with a non-2xx status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Temporary reports whether the request can succeed later:
+// on timeouts, rate limits and server errors.
+func (e *StatusError) Temporary() bool {
+	return false
+}
+
+// HTTPFetcher fetches pages with net/http and extracts `<a href>` links from HTML.
+// Links are resolved against the page URL and normalized, fragments are dropped.
+type HTTPFetcher struct {
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// MaxBodySize is the maximum size of a response body in bytes.
+	// Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+	// ContentTypes are allowed media types. Defaults to text/html only.
+	// Links are extracted only from text/html pages.
+	ContentTypes []string
+	// SameDomain keeps only links to the same host as the fetched page.
+	SameDomain bool
+}
+
+// Fetch implements Fetcher.
+func (f *HTTPFetcher) Fetch(url string) (string, []string, error) {
+	return f.FetchContext(context.Background(), url)
+}
+
+// FetchContext is Fetch that can be cancelled.
+// Use ContextFetcherFunc(f.FetchContext) as a ContextFetcher.
+func (f *HTTPFetcher) FetchContext(ctx context.Context, url string) (string, []string, error) {
+	return "", nil, nil
+}
+
+// ContextFetcherFunc adapts a function to ContextFetcher.
+type ContextFetcherFunc func(ctx context.Context, url string) (string, []string, error)
+
+func (f ContextFetcherFunc) Fetch(ctx context.Context, url string) (string, []string, error) {
+	return f(ctx, url)
+}
+
+const DefaultUserAgent = "gocrawler"
+
+var (
+	ErrExcluded   = errors.New("excluded by URL rules")
+	ErrDisallowed = errors.New("disallowed by robots.txt")
+)
+
+// Pattern matches URLs. *regexp.Regexp is a Pattern.
+type Pattern interface {
+	MatchString(s string) bool
+}
+
+// Glob returns a Pattern matching the whole URL, where
+// `*` matches any sequence of characters and `?` matches a single one.
+func Glob(pattern string) *regexp.Regexp {
+	return nil
+}
+
+// Filter decides which URLs can be fetched.
+type Filter struct {
+	// Include, if not empty, allows only URLs matching one of the patterns.
+	Include []Pattern
+	// Exclude rejects URLs matching any of the patterns. It wins over Include.
+	Exclude []Pattern
+	// Robots enables robots.txt rules. Crawl-delay keeps fetches from a host apart,
+	// a Crawler fetches other hosts meanwhile.
+	Robots bool
+	// UserAgent selects the robots.txt group. Defaults to DefaultUserAgent.
+	UserAgent string
+	// Client fetches robots.txt. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Wrap returns a ContextFetcher that checks the rules before anything
+// is fetched. Rejected URLs fail with ErrExcluded or ErrDisallowed.
+func (f *Filter) Wrap(fetcher ContextFetcher) ContextFetcher {
+	return nil
+}
+
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 100 * time.Millisecond
+	DefaultMaxDelay    = 5 * time.Second
+)
+
+// RetryPolicy retries fetches that failed with a retryable error.
+type RetryPolicy struct {
+	// MaxAttempts is the number of fetches of a URL, including the first one.
+	// Defaults to DefaultMaxAttempts.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles for every next one.
+	// Defaults to DefaultBaseDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay. Defaults to DefaultMaxDelay.
+	MaxDelay time.Duration
+	// Jitter is the fraction of the delay, from 0 to 1, that is randomized,
+	// so clients that failed together don't retry together. Zero means no jitter.
+	Jitter float64
+	// Retryable classifies errors. Defaults to IsRetryable.
+	Retryable func(error) bool
+}
+
+// IsRetryable reports whether a fetch that failed with err can succeed later.
+// Timeouts, including http.Client.Timeout, and cut responses are retryable.
+// Other errors with `Temporary() bool` method, like *StatusError, decide
+// themselves. Anything else, including cancellation and not found pages, is permanent.
+func IsRetryable(err error) bool {
+	return false
+}
+
+// Wrap returns a ContextFetcher that retries failed fetches with
+// exponential backoff. Permanent errors are returned right away.
+// After the last attempt the error says how many attempts were made.
+func (p *RetryPolicy) Wrap(fetcher ContextFetcher) ContextFetcher {
+	return nil
+}
+
+// WriteSitemap writes successfully fetched pages as sitemap.xml.
+func WriteSitemap(w io.Writer, report *Report) error {
+	return nil
+}
+
+// WriteJSON writes every page with its links and error, without bodies.
+func WriteJSON(w io.Writer, report *Report) error {
+	return nil
+}
+
+// WriteDOT writes the link graph in Graphviz DOT format.
+// Only links between crawled pages are drawn, failed pages are red.
+func WriteDOT(w io.Writer, report *Report) error {
+	return nil
+}
+
+// run is the whole crawler command, so it can be tested without a process.
+// It takes flags and a start URL in args and writes the crawl to stdout,
+// or to the file given with -o. A crawl stopped by ctx still writes
+// pages fetched so far.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	// TODO: Implement
+	return nil
+}
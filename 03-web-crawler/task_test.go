@@ -1,10 +1,24 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // fakeFetcher is Fetcher that returns canned results.
@@ -91,3 +105,1706 @@ func TestCrawl(t *testing.T) {
 		})
 	}
 }
+
+// golangOrg is the link graph of TestCrawl, shared by the tests below
+var golangOrg = fakeFetcher{
+	"https://golang.org/": &fakeResult{
+		"The Go Programming Language",
+		[]string{
+			"https://golang.org/pkg/",
+			"https://golang.org/cmd/",
+		},
+	},
+	"https://golang.org/pkg/": &fakeResult{
+		"Packages",
+		[]string{
+			"https://golang.org/",
+			"https://golang.org/cmd/",
+			"https://golang.org/pkg/fmt/",
+			"https://golang.org/pkg/os/",
+		},
+	},
+	"https://golang.org/pkg/fmt/": &fakeResult{
+		"Package fmt",
+		[]string{
+			"https://golang.org/",
+			"https://golang.org/pkg/",
+		},
+	},
+	"https://golang.org/pkg/os/": &fakeResult{
+		"Package os",
+		[]string{
+			"https://golang.org/",
+			"https://golang.org/pkg/",
+		},
+	},
+}
+
+// golangDev shares part of the link graph with golangOrg
+var golangDev = fakeFetcher{
+	"https://go.dev/": &fakeResult{
+		"Go",
+		[]string{
+			"https://golang.org/pkg/",
+			"https://go.dev/blog/",
+		},
+	},
+	"https://go.dev/blog/": &fakeResult{
+		"The Go Blog",
+		[]string{
+			"https://go.dev/",
+			"https://golang.org/pkg/fmt/",
+		},
+	},
+	"https://golang.org/pkg/": &fakeResult{
+		"Packages",
+		[]string{
+			"https://golang.org/pkg/fmt/",
+		},
+	},
+	"https://golang.org/pkg/fmt/": &fakeResult{
+		"Package fmt",
+		nil,
+	},
+}
+
+func TestCrawlParallel(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		fetcher fakeFetcher
+		result  []string
+	}{
+		{
+			name:    "golang.org",
+			url:     "https://golang.org/",
+			fetcher: golangOrg,
+			result:  []string{"Package fmt", "Package os", "Packages", "The Go Programming Language"},
+		},
+		{
+			name:    "go.dev",
+			url:     "https://go.dev/",
+			fetcher: golangDev,
+			result:  []string{"Go", "Package fmt", "Packages", "The Go Blog"},
+		},
+	}
+
+	for i := 0; i < 20; i++ {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+				result, err := Crawl(tt.url, 4, tt.fetcher)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Strings(result)
+				if !reflect.DeepEqual(tt.result, result) {
+					t.Errorf("Wrong result. Expected: %+q, Got: %+q", tt.result, result)
+				}
+			})
+		}
+	}
+}
+
+func TestCrawlerReuse(t *testing.T) {
+	c := NewCrawler(golangDev, Options{})
+
+	result, err := c.Crawl("https://golang.org/pkg/", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(result)
+	if want := []string{"Package fmt", "Packages"}; !reflect.DeepEqual(want, result) {
+		t.Errorf("Wrong first result. Expected: %+q, Got: %+q", want, result)
+	}
+
+	// Pages from the first crawl are not fetched again
+	result, err = c.Crawl("https://go.dev/", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(result)
+	if want := []string{"Go", "The Go Blog"}; !reflect.DeepEqual(want, result) {
+		t.Errorf("Wrong second result. Expected: %+q, Got: %+q", want, result)
+	}
+
+	all := c.Results()
+	sort.Strings(all)
+	if want := []string{"Go", "Package fmt", "Packages", "The Go Blog"}; !reflect.DeepEqual(want, all) {
+		t.Errorf("Wrong Results(). Expected: %+q, Got: %+q", want, all)
+	}
+}
+
+// slowFetcher tracks how many fetches are in flight at once, in total and per host.
+type slowFetcher struct {
+	Fetcher
+	delay        time.Duration
+	mu           sync.Mutex
+	inFlight     int
+	peak         int
+	hostInFlight map[string]int
+	hostPeak     map[string]int
+	hostStarts   map[string][]time.Time
+}
+
+func newSlowFetcher(f Fetcher, delay time.Duration) *slowFetcher {
+	return &slowFetcher{
+		Fetcher:      f,
+		delay:        delay,
+		hostInFlight: map[string]int{},
+		hostPeak:     map[string]int{},
+		hostStarts:   map[string][]time.Time{},
+	}
+}
+
+func (f *slowFetcher) Fetch(rawURL string) (string, []string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	f.mu.Lock()
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.hostInFlight[u.Host]++
+	f.hostPeak[u.Host] = max(f.hostPeak[u.Host], f.hostInFlight[u.Host])
+	f.hostStarts[u.Host] = append(f.hostStarts[u.Host], time.Now())
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	f.inFlight--
+	f.hostInFlight[u.Host]--
+	f.mu.Unlock()
+	return f.Fetcher.Fetch(rawURL)
+}
+
+// wideSite has a root page linking to n leaf pages spread across hosts
+func wideSite(n int, hosts ...string) fakeFetcher {
+	if len(hosts) == 0 {
+		hosts = []string{"example.com"}
+	}
+	f := fakeFetcher{"https://example.com/": &fakeResult{body: "root"}}
+	for i := 0; i < n; i++ {
+		u := fmt.Sprintf("https://%s/%d", hosts[i%len(hosts)], i)
+		f["https://example.com/"].urls = append(f["https://example.com/"].urls, u)
+		f[u] = &fakeResult{body: u}
+	}
+	return f
+}
+
+func TestCrawlerWorkers(t *testing.T) {
+	fetcher := newSlowFetcher(wideSite(20), 10*time.Millisecond)
+	c := NewCrawler(fetcher, Options{Workers: 3})
+
+	result, err := c.Crawl("https://example.com/", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 21 {
+		t.Errorf("Expected 21 pages, got %d", len(result))
+	}
+	if fetcher.peak > 3 {
+		t.Errorf("Expected at most 3 fetches in flight, got %d", fetcher.peak)
+	}
+}
+
+func TestCrawlerHostWorkers(t *testing.T) {
+	fetcher := newSlowFetcher(wideSite(30, "a.com", "b.com", "c.com"), 10*time.Millisecond)
+	c := NewCrawler(fetcher, Options{Workers: 6, HostWorkers: 2})
+
+	result, err := c.Crawl("https://example.com/", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 31 {
+		t.Errorf("Expected 31 pages, got %d", len(result))
+	}
+	for host, peak := range fetcher.hostPeak {
+		if peak > 2 {
+			t.Errorf("Expected at most 2 fetches in flight to %s, got %d", host, peak)
+		}
+	}
+	// Every host gets its share, so all workers were busy
+	if fetcher.peak != 6 {
+		t.Errorf("Expected 6 fetches in flight, got %d", fetcher.peak)
+	}
+}
+
+func TestCrawlerHostInterval(t *testing.T) {
+	fetcher := newSlowFetcher(wideSite(6, "a.com", "b.com"), 0)
+	c := NewCrawler(fetcher, Options{Workers: 4, HostInterval: 20 * time.Millisecond})
+
+	result, err := c.Crawl("https://example.com/", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 7 {
+		t.Errorf("Expected 7 pages, got %d", len(result))
+	}
+	for host, starts := range fetcher.hostStarts {
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+		for i := 1; i < len(starts); i++ {
+			// Some tolerance, because starts are recorded after the pacer lets us go
+			if gap := starts[i].Sub(starts[i-1]); gap < 15*time.Millisecond {
+				t.Errorf("Fetches from %s are %v apart, want at least 20ms", host, gap)
+			}
+		}
+	}
+}
+
+func TestCrawlerHostIntervalOtherHosts(t *testing.T) {
+	// b.com is the last in the queue, behind pages of a paced host
+	hosts := []string{"a.com", "a.com", "a.com", "a.com", "a.com", "a.com", "a.com", "b.com"}
+	fetcher := newSlowFetcher(wideSite(8, hosts...), 0)
+	c := NewCrawler(fetcher, Options{Workers: 2, HostInterval: 20 * time.Millisecond})
+
+	if _, err := c.Crawl("https://example.com/", 2); err != nil {
+		t.Fatal(err)
+	}
+	root, other := fetcher.hostStarts["example.com"][0], fetcher.hostStarts["b.com"][0]
+	// Workers don't wait for a.com, so b.com is fetched right away
+	if gap := other.Sub(root); gap > 15*time.Millisecond {
+		t.Errorf("Expected b.com to be fetched right after the root, took %v", gap)
+	}
+}
+
+// countingFetcher fails the test if any URL is fetched twice.
+type countingFetcher struct {
+	Fetcher
+	t     *testing.T
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (f *countingFetcher) Fetch(url string) (string, []string, error) {
+	f.mu.Lock()
+	f.calls[url]++
+	if f.calls[url] > 1 {
+		f.t.Errorf("%s fetched %d times", url, f.calls[url])
+	}
+	f.mu.Unlock()
+	return f.Fetcher.Fetch(url)
+}
+
+// denseSite has n pages, every page links to every page
+func denseSite(n int) fakeFetcher {
+	var urls []string
+	for i := 0; i < n; i++ {
+		urls = append(urls, fmt.Sprintf("https://example.com/%d", i))
+	}
+	f := fakeFetcher{}
+	for _, u := range urls {
+		f[u] = &fakeResult{body: u, urls: urls}
+	}
+	return f
+}
+
+func TestVisitedSets(t *testing.T) {
+	sets := map[string]func() VisitedSet{
+		"map":     func() VisitedSet { return NewMapSet() },
+		"sharded": func() VisitedSet { return NewShardedSet(8) },
+		"bloom":   func() VisitedSet { return NewBloomSet(1000, 0.001) },
+	}
+
+	for name, newSet := range sets {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				fetcher := &countingFetcher{Fetcher: denseSite(30), t: t, calls: map[string]int{}}
+				c := NewCrawler(fetcher, Options{Visited: newSet()})
+
+				result, err := c.Crawl("https://example.com/0", 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(result) != 30 {
+					t.Fatalf("Expected 30 pages, got %d", len(result))
+				}
+			}
+		})
+	}
+}
+
+func TestVisitedSetVisit(t *testing.T) {
+	sets := map[string]VisitedSet{
+		"map":     NewMapSet(),
+		"sharded": NewShardedSet(4),
+		"bloom":   NewBloomSet(100, 0.01),
+	}
+
+	for name, set := range sets {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			firsts := map[string]int{}
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						u := fmt.Sprintf("https://example.com/%d", j)
+						if set.Visit(u) {
+							mu.Lock()
+							firsts[u]++
+							mu.Unlock()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			for u, n := range firsts {
+				if n > 1 {
+					t.Errorf("Visit(%s) returned true %d times", u, n)
+				}
+			}
+			if name != "bloom" && len(firsts) != 50 {
+				t.Errorf("Expected 50 new URLs, got %d", len(firsts))
+			}
+		})
+	}
+}
+
+// ctxFetcher is a ContextFetcher that takes delay for every page,
+// unless ctx is done earlier.
+type ctxFetcher struct {
+	fakeFetcher
+	delay time.Duration
+}
+
+func (f ctxFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	select {
+	case <-time.After(f.delay):
+		return f.fakeFetcher.Fetch(url)
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+}
+
+func TestCrawlContextCancel(t *testing.T) {
+	fetcher := newSlowFetcher(wideSite(20), 30*time.Millisecond)
+	c := NewCrawler(fetcher, Options{Workers: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(80*time.Millisecond, cancel)
+
+	result, err := c.CrawlContext(ctx, "https://example.com/", 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+	if len(result) < 2 || len(result) > 20 {
+		t.Errorf("Expected partial result, got %d pages", len(result))
+	}
+
+	// Fetches in flight must be finished and counted
+	fetcher.mu.Lock()
+	defer fetcher.mu.Unlock()
+	if fetcher.inFlight != 0 {
+		t.Errorf("Expected no fetches in flight, got %d", fetcher.inFlight)
+	}
+	if started := len(fetcher.hostStarts["example.com"]); started != len(result) {
+		t.Errorf("Expected %d fetches, got %d", len(result), started)
+	}
+}
+
+func TestCrawlContextCancelResume(t *testing.T) {
+	site := wideSite(20)
+	fetcher := newSlowFetcher(site, 30*time.Millisecond)
+	c := NewCrawler(fetcher, Options{Workers: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(80*time.Millisecond, cancel)
+	if _, err := c.CrawlContext(ctx, "https://example.com/", 2); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	// Pages found but not fetched are not visited yet
+	for _, u := range site["https://example.com/"].urls {
+		if _, err := c.Crawl(u, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if results := c.Results(); len(results) != 21 {
+		t.Errorf("Expected 21 pages, got %d", len(results))
+	}
+	if started := len(fetcher.hostStarts["example.com"]); started != 21 {
+		t.Errorf("Expected 21 fetches, got %d", started)
+	}
+}
+
+func TestCrawlContextDeadline(t *testing.T) {
+	fetcher := ctxFetcher{fakeFetcher: wideSite(20), delay: 50 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 125*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result, err := CrawlContext(ctx, "https://example.com/", 2, fetcher)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	// Root and the first batch of leaves are fetched, the second batch is cancelled
+	if len(result) != 11 {
+		t.Errorf("Expected 11 pages, got %d", len(result))
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Expected crawl to stop on deadline, took %v", elapsed)
+	}
+}
+
+func TestCrawlContextRootError(t *testing.T) {
+	_, err := CrawlContext(context.Background(), "https://example.com/missing", 2, WithContext(golangOrg))
+	if err == nil {
+		t.Error("Expected error for missing root page")
+	}
+}
+
+func TestCrawlReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		depth   int
+		fetcher fakeFetcher
+		pages   []Page
+		graph   map[string][]string
+		err     bool
+	}{
+		{
+			name:    "golang.org",
+			url:     "https://golang.org/",
+			depth:   4,
+			fetcher: golangOrg,
+			pages: []Page{
+				{URL: "https://golang.org/", Body: "The Go Programming Language", Links: golangOrg["https://golang.org/"].urls},
+				{URL: "https://golang.org/pkg/", Depth: 1, Parent: "https://golang.org/", Body: "Packages", Links: golangOrg["https://golang.org/pkg/"].urls},
+				{URL: "https://golang.org/cmd/", Depth: 1, Parent: "https://golang.org/", Err: errors.New("not found: https://golang.org/cmd/")},
+				{URL: "https://golang.org/pkg/fmt/", Depth: 2, Parent: "https://golang.org/pkg/", Body: "Package fmt", Links: golangOrg["https://golang.org/pkg/fmt/"].urls},
+				{URL: "https://golang.org/pkg/os/", Depth: 2, Parent: "https://golang.org/pkg/", Body: "Package os", Links: golangOrg["https://golang.org/pkg/os/"].urls},
+			},
+			graph: map[string][]string{
+				"https://golang.org/":         golangOrg["https://golang.org/"].urls,
+				"https://golang.org/pkg/":     golangOrg["https://golang.org/pkg/"].urls,
+				"https://golang.org/pkg/fmt/": golangOrg["https://golang.org/pkg/fmt/"].urls,
+				"https://golang.org/pkg/os/":  golangOrg["https://golang.org/pkg/os/"].urls,
+			},
+		},
+		{
+			name:    "depth limit",
+			url:     "https://go.dev/",
+			depth:   2,
+			fetcher: golangDev,
+			pages: []Page{
+				{URL: "https://go.dev/", Body: "Go", Links: golangDev["https://go.dev/"].urls},
+				{URL: "https://golang.org/pkg/", Depth: 1, Parent: "https://go.dev/", Body: "Packages", Links: golangDev["https://golang.org/pkg/"].urls},
+				{URL: "https://go.dev/blog/", Depth: 1, Parent: "https://go.dev/", Body: "The Go Blog", Links: golangDev["https://go.dev/blog/"].urls},
+			},
+			graph: map[string][]string{
+				"https://go.dev/":         golangDev["https://go.dev/"].urls,
+				"https://golang.org/pkg/": golangDev["https://golang.org/pkg/"].urls,
+				"https://go.dev/blog/":    golangDev["https://go.dev/blog/"].urls,
+			},
+		},
+		{
+			name:    "root error",
+			url:     "https://golang.org/missing",
+			depth:   2,
+			fetcher: golangOrg,
+			pages: []Page{
+				{URL: "https://golang.org/missing", Err: errors.New("not found: https://golang.org/missing")},
+			},
+			graph: map[string][]string{},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Order must not depend on timing, so check it many times
+			for i := 0; i < 20; i++ {
+				c := NewCrawler(tt.fetcher, Options{Workers: 1 + i%4})
+				report, err := c.CrawlReport(context.Background(), tt.url, tt.depth)
+				if (err != nil) != tt.err {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+
+				if len(report.Pages) != len(tt.pages) {
+					t.Fatalf("Expected %d pages, got %d: %+v", len(tt.pages), len(report.Pages), report.Pages)
+				}
+				for j, got := range report.Pages {
+					want := tt.pages[j]
+					if fmt.Sprint(got.Err) != fmt.Sprint(want.Err) {
+						t.Errorf("Page %d: expected error %v, got %v", j, want.Err, got.Err)
+					}
+					got.Err, want.Err = nil, nil
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("Page %d: expected %+v, got %+v", j, want, got)
+					}
+				}
+				if !reflect.DeepEqual(report.Graph, tt.graph) {
+					t.Errorf("Expected graph %v, got %v", tt.graph, report.Graph)
+				}
+			}
+		})
+	}
+}
+
+// gateFetcher blocks fetches of slow until open is fetched, or for a second.
+type gateFetcher struct {
+	fakeFetcher
+	slow, open string
+	gate       chan struct{}
+}
+
+func (f gateFetcher) Fetch(url string) (string, []string, error) {
+	switch url {
+	case f.slow:
+		select {
+		case <-f.gate:
+		case <-time.After(time.Second):
+		}
+	case f.open:
+		close(f.gate)
+	}
+	return f.fakeFetcher.Fetch(url)
+}
+
+func TestCrawlReportSlowPage(t *testing.T) {
+	site := fakeFetcher{
+		"https://example.com/":       {body: "root", urls: []string{"https://example.com/slow", "https://example.com/fast"}},
+		"https://example.com/slow":   {body: "slow", urls: []string{"https://example.com/shared"}},
+		"https://example.com/fast":   {body: "fast", urls: []string{"https://example.com/shared", "https://example.com/deep"}},
+		"https://example.com/shared": {body: "shared"},
+		"https://example.com/deep":   {body: "deep"},
+	}
+	fetcher := gateFetcher{fakeFetcher: site, slow: "https://example.com/slow", open: "https://example.com/deep", gate: make(chan struct{})}
+
+	start := time.Now()
+	report, err := NewCrawler(fetcher, Options{Workers: 2}).CrawlReport(context.Background(), "https://example.com/", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Slow page doesn't keep pages found by others from being fetched
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected crawl not to wait for the slow page, took %v", elapsed)
+	}
+
+	// Shared page is fetched through the fast page, but the slow one is its parent in BFS order
+	want := []string{
+		"https://example.com/ (depth 0, parent )",
+		"https://example.com/slow (depth 1, parent https://example.com/)",
+		"https://example.com/fast (depth 1, parent https://example.com/)",
+		"https://example.com/shared (depth 2, parent https://example.com/slow)",
+		"https://example.com/deep (depth 2, parent https://example.com/fast)",
+	}
+	var got []string
+	for _, p := range report.Pages {
+		got = append(got, fmt.Sprintf("%s (depth %d, parent %s)", p.URL, p.Depth, p.Parent))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected pages %v, got %v", want, got)
+	}
+}
+
+// newTestSite serves HTML pages, where {{host}} is replaced with the server host.
+func newTestSite(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG"))
+			return
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(strings.Repeat("a", 2048)))
+			return
+		case "/redirect":
+			http.Redirect(w, r, "/docs/", http.StatusFound)
+			return
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(strings.ReplaceAll(page, "{{host}}", strings.TrimPrefix(srv.URL, "http://"))))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var testSitePages = map[string]string{
+	"/": `<html><body>
+		<a href="docs/">Docs</a>
+		<a href="/blog#latest">Blog</a>
+		<a href="HTTP://{{host}}/blog">Blog again</a>
+		<a href="https://Other.Example:443/x?q=1#top">Other</a>
+		<a href="mailto:gopher@example.com">Mail</a>
+		<a href="javascript:void(0)">Nothing</a>
+		<a name="anchor">No href</a>
+	</body></html>`,
+	"/docs/": `<html><head><base href="/docs/v2/"></head><body>
+		<a href="intro">Intro</a>
+		<a href="../">Up</a>
+	</body></html>`,
+	"/blog": `<a href="/">Home</a><a href="/missing">Missing</a><a href="/image.png">Image</a>`,
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	srv := newTestSite(t, testSitePages)
+
+	tests := []struct {
+		name    string
+		fetcher *HTTPFetcher
+		path    string
+		links   []string
+		err     error
+		status  int
+	}{
+		{
+			name:    "links are resolved and normalized",
+			fetcher: &HTTPFetcher{},
+			path:    "/",
+			links: []string{
+				srv.URL + "/docs/",
+				srv.URL + "/blog",
+				"https://other.example/x?q=1",
+			},
+		},
+		{
+			name:    "same domain",
+			fetcher: &HTTPFetcher{SameDomain: true},
+			path:    "/",
+			links: []string{
+				srv.URL + "/docs/",
+				srv.URL + "/blog",
+			},
+		},
+		{
+			name:    "base element",
+			fetcher: &HTTPFetcher{},
+			path:    "/docs/",
+			links: []string{
+				srv.URL + "/docs/v2/intro",
+				srv.URL + "/docs/",
+			},
+		},
+		{
+			name:    "links are relative to redirect target",
+			fetcher: &HTTPFetcher{},
+			path:    "/redirect",
+			links: []string{
+				srv.URL + "/docs/v2/intro",
+				srv.URL + "/docs/",
+			},
+		},
+		{
+			name:    "not found",
+			fetcher: &HTTPFetcher{},
+			path:    "/missing",
+			status:  http.StatusNotFound,
+		},
+		{
+			name:    "content type not allowed",
+			fetcher: &HTTPFetcher{},
+			path:    "/image.png",
+			err:     ErrContentType,
+		},
+		{
+			name:    "content type allowed",
+			fetcher: &HTTPFetcher{ContentTypes: []string{"text/html", "image/png"}},
+			path:    "/image.png",
+		},
+		{
+			name:    "body too large",
+			fetcher: &HTTPFetcher{MaxBodySize: 1024},
+			path:    "/big",
+			err:     ErrBodyTooLarge,
+		},
+		{
+			name:    "body within limit",
+			fetcher: &HTTPFetcher{MaxBodySize: 2048},
+			path:    "/big",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, links, err := tt.fetcher.Fetch(srv.URL + tt.path)
+			if tt.status != 0 {
+				var statusErr *StatusError
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+					t.Fatalf("Expected status %d, got %v", tt.status, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(links, tt.links) {
+				t.Errorf("Wrong links. Expected: %q, Got: %q", tt.links, links)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherCrawl(t *testing.T) {
+	srv := newTestSite(t, testSitePages)
+	fetcher := &HTTPFetcher{SameDomain: true}
+	c := NewContextCrawler(ContextFetcherFunc(fetcher.FetchContext), Options{})
+
+	report, err := c.CrawlReport(context.Background(), srv.URL+"/", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range report.Pages {
+		status := "ok"
+		if p.Err != nil {
+			status = "error"
+		}
+		got = append(got, strings.TrimPrefix(p.URL, srv.URL)+" "+status)
+	}
+	want := []string{
+		"/ ok",
+		"/docs/ ok",
+		"/blog ok",
+		"/docs/v2/intro error",
+		"/missing error",
+		"/image.png error",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong pages. Expected: %q, Got: %q", want, got)
+	}
+}
+
+// robotsSite serves robots.txt with the given status and body,
+// and HTML pages from pages. It counts robots.txt requests.
+type robotsSite struct {
+	*httptest.Server
+	robotsHits atomic.Int32
+}
+
+func newRobotsSite(t *testing.T, status int, robots string, pages map[string]string) *robotsSite {
+	t.Helper()
+	site := &robotsSite{}
+	site.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			site.robotsHits.Add(1)
+			w.WriteHeader(status)
+			w.Write([]byte(robots))
+			return
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			page = "ok"
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(site.Close)
+	return site
+}
+
+const testRobots = `# Test robots.txt
+User-agent: *
+Disallow: /private/
+Allow: /private/open
+Disallow: /*.pdf$
+Disallow: /search?
+
+User-agent: gocrawler
+User-agent: otherbot
+Disallow: /agent-only # only for us
+Disallow:
+`
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		robots string
+		filter func(base string) *Filter
+		allow  []string
+		deny   map[string]error
+	}{
+		{
+			name: "include glob",
+			filter: func(base string) *Filter {
+				return &Filter{Include: []Pattern{Glob(base + "/docs/*"), Glob(base + "/v?")}}
+			},
+			allow: []string{"/docs/", "/docs/a/b", "/v1"},
+			deny:  map[string]error{"/blog": ErrExcluded, "/v10": ErrExcluded, "/": ErrExcluded},
+		},
+		{
+			name: "exclude wins over include",
+			filter: func(base string) *Filter {
+				return &Filter{
+					Include: []Pattern{Glob(base + "/*")},
+					Exclude: []Pattern{regexp.MustCompile(`\.(png|jpg)$`)},
+				}
+			},
+			allow: []string{"/", "/a.png?size=2"},
+			deny:  map[string]error{"/a.png": ErrExcluded, "/b/c.jpg": ErrExcluded},
+		},
+		{
+			name:   "robots.txt * group",
+			status: http.StatusOK,
+			robots: testRobots,
+			filter: func(string) *Filter { return &Filter{Robots: true, UserAgent: "somebot"} },
+			allow:  []string{"/", "/private", "/private/open/x", "/a.pdf?download", "/search", "/agent-only"},
+			deny: map[string]error{
+				"/private/":     ErrDisallowed,
+				"/private/file": ErrDisallowed,
+				"/docs/a.pdf":   ErrDisallowed,
+				"/search?q=go":  ErrDisallowed,
+			},
+		},
+		{
+			name:   "robots.txt user agent group",
+			status: http.StatusOK,
+			robots: testRobots,
+			filter: func(string) *Filter { return &Filter{Robots: true} },
+			allow:  []string{"/", "/private/file", "/a.pdf"},
+			deny:   map[string]error{"/agent-only": ErrDisallowed, "/agent-only/x": ErrDisallowed},
+		},
+		{
+			name:   "robots.txt user agent matches whole product token",
+			status: http.StatusOK,
+			robots: "User-agent: crawler\nUser-agent: go\nDisallow: /\n\nUser-agent: GOCRAWLER\nDisallow: /agent-only\n",
+			filter: func(string) *Filter {
+				return &Filter{Robots: true, UserAgent: "GoCrawler/1.0 (+https://example.com/bot)"}
+			},
+			allow: []string{"/", "/docs/"},
+			deny:  map[string]error{"/agent-only": ErrDisallowed},
+		},
+		{
+			name:   "URL rules are checked first",
+			status: http.StatusOK,
+			robots: testRobots,
+			filter: func(base string) *Filter {
+				return &Filter{Robots: true, Exclude: []Pattern{Glob(base + "/agent-only")}}
+			},
+			deny: map[string]error{"/agent-only": ErrExcluded},
+		},
+		{
+			name:   "missing robots.txt allows everything",
+			status: http.StatusNotFound,
+			robots: "User-agent: *\nDisallow: /\n",
+			filter: func(string) *Filter { return &Filter{Robots: true} },
+			allow:  []string{"/", "/private/file"},
+		},
+		{
+			name:   "unavailable robots.txt disallows everything",
+			status: http.StatusServiceUnavailable,
+			filter: func(string) *Filter { return &Filter{Robots: true} },
+			deny:   map[string]error{"/": ErrDisallowed, "/docs/": ErrDisallowed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := newRobotsSite(t, tt.status, tt.robots, nil)
+
+			var mu sync.Mutex
+			fetched := make(map[string]bool)
+			fetcher := tt.filter(site.URL).Wrap(ContextFetcherFunc(func(ctx context.Context, url string) (string, []string, error) {
+				mu.Lock()
+				fetched[strings.TrimPrefix(url, site.URL)] = true
+				mu.Unlock()
+				return "ok", nil, nil
+			}))
+
+			for _, path := range tt.allow {
+				if _, _, err := fetcher.Fetch(context.Background(), site.URL+path); err != nil {
+					t.Errorf("%s: unexpected error %v", path, err)
+				}
+				if !fetched[path] {
+					t.Errorf("%s: expected to be fetched", path)
+				}
+			}
+			for path, want := range tt.deny {
+				if _, _, err := fetcher.Fetch(context.Background(), site.URL+path); !errors.Is(err, want) {
+					t.Errorf("%s: expected error %v, got %v", path, want, err)
+				}
+				if fetched[path] {
+					t.Errorf("%s: rejected URL was fetched", path)
+				}
+			}
+			if hits := site.robotsHits.Load(); hits > 1 {
+				t.Errorf("Expected robots.txt to be fetched at most once, got %d", hits)
+			}
+		})
+	}
+}
+
+func TestFilterCrawlDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	site := newRobotsSite(t, http.StatusOK, "User-agent: *\nCrawl-delay: 0.05\n", nil)
+	other := newRobotsSite(t, http.StatusNotFound, "", nil)
+
+	var mu sync.Mutex
+	var times []time.Time
+	fetcher := (&Filter{Robots: true}).Wrap(ContextFetcherFunc(func(ctx context.Context, url string) (string, []string, error) {
+		if strings.HasPrefix(url, site.URL) {
+			mu.Lock()
+			times = append(times, time.Now())
+			mu.Unlock()
+		}
+		return "ok", nil, nil
+	}))
+
+	// Other hosts are not paced
+	start := time.Now()
+	for i := range 5 {
+		if _, _, err := fetcher.Fetch(context.Background(), fmt.Sprintf("%s/%d", other.URL, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= delay {
+		t.Errorf("Host without Crawl-delay took %v", elapsed)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := fetcher.Fetch(context.Background(), fmt.Sprintf("%s/%d", site.URL, i)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := 1; i < len(times); i++ {
+		// Timers are not precise, allow some slack
+		if gap := times[i].Sub(times[i-1]); gap < delay-15*time.Millisecond {
+			t.Errorf("Fetches %d and %d are only %v apart", i-1, i, gap)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := fetcher.Fetch(ctx, site.URL+"/cancelled"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestFilterCrawlDelayOtherHosts(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	paced := newRobotsSite(t, http.StatusOK, "User-agent: *\nCrawl-delay: 0.05\n", nil)
+	other := newRobotsSite(t, http.StatusNotFound, "", nil)
+
+	// Other host is the last in the queue, behind pages of the paced one
+	root := paced.URL + "/"
+	links := []string{paced.URL + "/1", paced.URL + "/2", paced.URL + "/3", other.URL + "/"}
+	var mu sync.Mutex
+	starts := make(map[string]time.Time)
+	fetcher := (&Filter{Robots: true}).Wrap(ContextFetcherFunc(func(ctx context.Context, url string) (string, []string, error) {
+		mu.Lock()
+		starts[url] = time.Now()
+		mu.Unlock()
+		if url == root {
+			return "root", links, nil
+		}
+		return "ok", nil, nil
+	}))
+
+	report, err := NewContextCrawler(fetcher, Options{Workers: 1}).CrawlReport(context.Background(), root, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range report.Pages {
+		if p.Err != nil {
+			t.Errorf("%s: %v", p.URL, p.Err)
+		}
+	}
+	if len(starts) != 5 {
+		t.Fatalf("Expected 5 fetches, got %d", len(starts))
+	}
+	// Worker doesn't wait for Crawl-delay, so the other host is fetched right away
+	if gap := starts[other.URL+"/"].Sub(starts[root]); gap >= delay {
+		t.Errorf("Expected other host to be fetched right after the root, took %v", gap)
+	}
+	var last time.Time
+	for _, u := range links[:3] {
+		if starts[u].After(last) {
+			last = starts[u]
+		}
+	}
+	if gap := last.Sub(starts[root]); gap < 3*delay-15*time.Millisecond {
+		t.Errorf("Expected paced host to keep Crawl-delay, took %v for 3 pages", gap)
+	}
+}
+
+func TestFilterCrawl(t *testing.T) {
+	site := newRobotsSite(t, http.StatusOK, "User-agent: *\nDisallow: /private/\n", map[string]string{
+		"/":      `<a href="/docs/">Docs</a><a href="/private/">Private</a><a href="/files/a.zip">File</a>`,
+		"/docs/": `<a href="/private/key">Key</a><a href="/">Home</a>`,
+	})
+	filter := &Filter{Robots: true, Exclude: []Pattern{Glob("*.zip")}}
+	fetcher := &HTTPFetcher{SameDomain: true}
+	c := NewContextCrawler(filter.Wrap(ContextFetcherFunc(fetcher.FetchContext)), Options{})
+
+	report, err := c.CrawlReport(context.Background(), site.URL+"/", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range report.Pages {
+		status := "ok"
+		switch {
+		case errors.Is(p.Err, ErrDisallowed):
+			status = "disallowed"
+		case errors.Is(p.Err, ErrExcluded):
+			status = "excluded"
+		case p.Err != nil:
+			status = "error"
+		}
+		got = append(got, strings.TrimPrefix(p.URL, site.URL)+" "+status)
+	}
+	want := []string{
+		"/ ok",
+		"/docs/ ok",
+		"/private/ disallowed",
+		"/files/a.zip excluded",
+		"/private/key disallowed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong pages. Expected: %q, Got: %q", want, got)
+	}
+	if hits := site.robotsHits.Load(); hits != 1 {
+		t.Errorf("Expected robots.txt to be fetched once, got %d", hits)
+	}
+}
+
+// treeSite is a tree of pages with fanout links on each level.
+// Every page also links to the root and to a missing page.
+func treeSite(fanout, depth int) fakeFetcher {
+	const root = "https://example.com/"
+	f := fakeFetcher{}
+	var add func(url string, level int)
+	add = func(url string, level int) {
+		res := &fakeResult{body: "page " + url, urls: []string{root, root + "missing"}}
+		f[url] = res
+		if level == depth {
+			return
+		}
+		for i := range fanout {
+			child := fmt.Sprintf("%s%d/", url, i)
+			res.urls = append(res.urls, child)
+			add(child, level+1)
+		}
+	}
+	add(root, 0)
+	return f
+}
+
+// journalFetcher records every fetch and cancels the crawl after limit fetches.
+type journalFetcher struct {
+	fakeFetcher
+	mu      sync.Mutex
+	fetched map[string]int
+	limit   int
+	cancel  context.CancelFunc
+}
+
+func (f *journalFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	f.mu.Lock()
+	f.fetched[url]++
+	if f.limit > 0 && len(f.fetched) >= f.limit {
+		f.cancel()
+	}
+	f.mu.Unlock()
+	return f.fakeFetcher.Fetch(url)
+}
+
+// pageSummaries makes reports comparable, restored errors keep only their text.
+func pageSummaries(report *Report) []string {
+	var res []string
+	for _, p := range report.Pages {
+		res = append(res, fmt.Sprintf("%s depth=%d parent=%s body=%q links=%q err=%v", p.URL, p.Depth, p.Parent, p.Body, p.Links, p.Err))
+	}
+	return res
+}
+
+func TestCrawlCheckpoint(t *testing.T) {
+	const root, depth = "https://example.com/", 4
+	site := treeSite(3, 3)
+	want, err := NewCrawler(site, Options{}).CrawlReport(context.Background(), root, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, limit := range []int{1, 3, 10, 25} {
+		t.Run(fmt.Sprintf("interrupted after %d", limit), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "crawl.jsonl")
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			fetcher := &journalFetcher{fakeFetcher: site, fetched: make(map[string]int), limit: limit, cancel: cancel}
+
+			c := NewContextCrawler(fetcher, Options{Workers: 3, Checkpoint: path})
+			if _, err := c.CrawlReport(ctx, root, depth); !errors.Is(err, context.Canceled) {
+				t.Fatalf("Expected %v, got %v", context.Canceled, err)
+			}
+			interrupted := len(fetcher.fetched)
+
+			// A crash can leave the last line incomplete
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString(`{"kind":"page","url":"https://exa`)
+			f.Close()
+
+			fetcher.limit = 0
+			c = NewContextCrawler(fetcher, Options{Workers: 3, Checkpoint: path})
+			got, err := c.CrawlReport(context.Background(), root, depth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pageSummaries(got), pageSummaries(want)) {
+				t.Errorf("Wrong pages. Expected:\n%s\nGot:\n%s", strings.Join(pageSummaries(want), "\n"), strings.Join(pageSummaries(got), "\n"))
+			}
+			if !reflect.DeepEqual(got.Graph, want.Graph) {
+				t.Errorf("Expected graph %v, got %v", want.Graph, got.Graph)
+			}
+			for url, n := range fetcher.fetched {
+				if n > 1 {
+					t.Errorf("%s fetched %d times", url, n)
+				}
+			}
+			if len(fetcher.fetched) != len(want.Pages) {
+				t.Errorf("Expected %d fetches, got %d (%d before interruption)", len(want.Pages), len(fetcher.fetched), interrupted)
+			}
+
+			// Finished checkpoint restores the whole crawl
+			fetcher.fetched = make(map[string]int)
+			c = NewContextCrawler(fetcher, Options{Checkpoint: path})
+			got, err = c.CrawlReport(context.Background(), root, depth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fetcher.fetched) != 0 {
+				t.Errorf("Expected no fetches, got %v", fetcher.fetched)
+			}
+			if !reflect.DeepEqual(pageSummaries(got), pageSummaries(want)) {
+				t.Errorf("Wrong restored pages")
+			}
+			if results := c.Results(); len(results) != len(got.Graph) {
+				t.Errorf("Expected %d results, got %d", len(got.Graph), len(results))
+			}
+		})
+	}
+}
+
+func TestCrawlCheckpointMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.jsonl")
+	if _, err := NewCrawler(golangOrg, Options{Checkpoint: path}).Crawl("https://golang.org/", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		url   string
+		depth int
+	}{
+		{"https://golang.org/pkg/", 2},
+		{"https://golang.org/", 3},
+	} {
+		_, err := NewCrawler(golangOrg, Options{Checkpoint: path}).Crawl(tt.url, tt.depth)
+		if !errors.Is(err, ErrCheckpointMismatch) {
+			t.Errorf("%s with depth %d: expected %v, got %v", tt.url, tt.depth, ErrCheckpointMismatch, err)
+		}
+	}
+}
+
+func TestCrawlCheckpointFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.jsonl")
+	if _, err := NewCrawler(golangOrg, Options{Checkpoint: path}).Crawl("https://golang.org/", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	// crawl, root page, two pages
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d:\n%s", len(lines), data)
+	}
+	for i, line := range lines {
+		var v map[string]any
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Errorf("Line %d is not JSON: %v", i+1, err)
+		}
+	}
+}
+
+// errTransient is a retryable error of flakyFetcher.
+type errTransient struct{ url string }
+
+func (e errTransient) Error() string   { return "transient error: " + e.url }
+func (e errTransient) Temporary() bool { return true }
+
+// flakyFetcher fails every URL failures[url] times before it succeeds.
+type flakyFetcher struct {
+	fakeFetcher
+	failures map[string]int
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+func newFlakyFetcher(f fakeFetcher, failures map[string]int) *flakyFetcher {
+	return &flakyFetcher{fakeFetcher: f, failures: failures, attempts: make(map[string]int)}
+}
+
+func (f *flakyFetcher) Fetch(ctx context.Context, url string) (string, []string, error) {
+	f.mu.Lock()
+	f.attempts[url]++
+	n := f.attempts[url]
+	f.mu.Unlock()
+	if n <= f.failures[url] {
+		return "", nil, errTransient{url}
+	}
+	return f.fakeFetcher.Fetch(url)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"not found", errors.New("not found"), false},
+		{"canceled", context.Canceled, false},
+		{"deadline", fmt.Errorf("fetch: %w", context.DeadlineExceeded), true},
+		{"temporary", errTransient{"https://example.com/"}, true},
+		{"status 404", &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"status 408", &StatusError{StatusCode: http.StatusRequestTimeout}, true},
+		{"status 429", &StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{"status 500", &StatusError{StatusCode: http.StatusInternalServerError}, true},
+		{"status 501", &StatusError{StatusCode: http.StatusNotImplemented}, false},
+		{"wrapped status 503", fmt.Errorf("fetch: %w", &StatusError{StatusCode: http.StatusServiceUnavailable}), true},
+		{"timeout", &url.Error{Op: "Get", URL: "https://example.com/", Err: os.ErrDeadlineExceeded}, true},
+		{"cut response", io.ErrUnexpectedEOF, true},
+		{"content type", ErrContentType, false},
+		{"disallowed", ErrDisallowed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+	// Client timeout is context.DeadlineExceeded too, but the crawl goes on
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	fetcher := &HTTPFetcher{Client: &http.Client{Timeout: 20 * time.Millisecond}}
+	_, _, err := fetcher.FetchContext(context.Background(), slow.URL)
+	if err == nil || !IsRetryable(err) {
+		t.Errorf("Expected client timeout to be retryable, got %v", err)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	const root = "https://golang.org/"
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		url      string
+		failures int
+		attempts int
+		wantErr  bool
+		err      error
+	}{
+		{
+			name:     "no failures",
+			url:      root,
+			attempts: 1,
+		},
+		{
+			name:     "succeeds after retries",
+			policy:   RetryPolicy{MaxAttempts: 3},
+			url:      root,
+			failures: 2,
+			attempts: 3,
+		},
+		{
+			name:     "gives up after max attempts",
+			policy:   RetryPolicy{MaxAttempts: 3},
+			url:      root,
+			failures: 5,
+			attempts: 3,
+			wantErr:  true,
+			err:      errTransient{root},
+		},
+		{
+			name:     "default max attempts",
+			url:      root,
+			failures: 5,
+			attempts: DefaultMaxAttempts,
+			wantErr:  true,
+			err:      errTransient{root},
+		},
+		{
+			name:     "permanent error is not retried",
+			policy:   RetryPolicy{MaxAttempts: 5},
+			url:      "https://golang.org/missing",
+			attempts: 1,
+			wantErr:  true,
+		},
+		{
+			name: "custom classification",
+			policy: RetryPolicy{MaxAttempts: 5, Retryable: func(err error) bool {
+				return strings.HasPrefix(err.Error(), "not found")
+			}},
+			url:      "https://golang.org/missing",
+			attempts: 5,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.BaseDelay = time.Millisecond
+			fetcher := newFlakyFetcher(golangOrg, map[string]int{tt.url: tt.failures})
+
+			_, _, err := tt.policy.Wrap(fetcher).Fetch(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Expected error %v, got %v", tt.err, err)
+			}
+			if got := fetcher.attempts[tt.url]; got != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	const base = 10 * time.Millisecond
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		min, max time.Duration
+	}{
+		// 10ms + 20ms + 40ms
+		{"exponential", RetryPolicy{MaxAttempts: 4, BaseDelay: base}, 70 * time.Millisecond, 150 * time.Millisecond},
+		// 10ms + 20ms + 20ms
+		{"max delay", RetryPolicy{MaxAttempts: 4, BaseDelay: base, MaxDelay: 2 * base}, 50 * time.Millisecond, 130 * time.Millisecond},
+		// At least half of 70ms
+		{"jitter", RetryPolicy{MaxAttempts: 4, BaseDelay: base, Jitter: 0.5}, 35 * time.Millisecond, 150 * time.Millisecond},
+		// 10ms + 10ms + 10ms, BaseDelay<<1 doesn't fit in time.Duration
+		{"no overflow", RetryPolicy{MaxAttempts: 4, BaseDelay: 1 << 62, MaxDelay: base}, 30 * time.Millisecond, 110 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := newFlakyFetcher(golangOrg, map[string]int{"https://golang.org/": 10})
+			start := time.Now()
+			tt.policy.Wrap(fetcher).Fetch(context.Background(), "https://golang.org/")
+			if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("Expected backoff between %v and %v, took %v", tt.min, tt.max, elapsed)
+			}
+		})
+	}
+
+	t.Run("cancelled during backoff", func(t *testing.T) {
+		fetcher := newFlakyFetcher(golangOrg, map[string]int{"https://golang.org/": 10})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, _, err := (&RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second}).Wrap(fetcher).Fetch(ctx, "https://golang.org/")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected backoff to stop on cancel, took %v", elapsed)
+		}
+		if got := fetcher.attempts["https://golang.org/"]; got != 1 {
+			t.Errorf("Expected 1 attempt, got %d", got)
+		}
+	})
+}
+
+func TestRetryPolicyCrawl(t *testing.T) {
+	// Without retries a failure of pkg/ drops fmt/ and os/ too
+	fetcher := newFlakyFetcher(golangOrg, map[string]int{
+		"https://golang.org/":     1,
+		"https://golang.org/pkg/": 2,
+	})
+	policy := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5}
+	c := NewContextCrawler(policy.Wrap(fetcher), Options{})
+
+	report, err := c.CrawlReport(context.Background(), "https://golang.org/", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range report.Pages {
+		status := "ok"
+		if p.Err != nil {
+			status = "error"
+		}
+		got = append(got, fmt.Sprintf("%s %s attempts=%d", p.URL, status, fetcher.attempts[p.URL]))
+	}
+	want := []string{
+		"https://golang.org/ ok attempts=2",
+		"https://golang.org/pkg/ ok attempts=3",
+		"https://golang.org/cmd/ error attempts=1",
+		"https://golang.org/pkg/fmt/ ok attempts=1",
+		"https://golang.org/pkg/os/ ok attempts=1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong pages. Expected: %q, Got: %q", want, got)
+	}
+}
+
+var writerReport = &Report{
+	Pages: []Page{
+		{URL: "https://example.com/", Body: "root", Links: []string{"https://example.com/a?x=1&y=2", "https://example.com/missing", "https://other.example/"}},
+		{URL: "https://example.com/a?x=1&y=2", Depth: 1, Parent: "https://example.com/", Links: []string{"https://example.com/"}},
+		{URL: "https://example.com/missing", Depth: 1, Parent: "https://example.com/", Err: errors.New("not found")},
+	},
+	Graph: map[string][]string{
+		"https://example.com/":          {"https://example.com/a?x=1&y=2", "https://example.com/missing", "https://other.example/"},
+		"https://example.com/a?x=1&y=2": {"https://example.com/"},
+	},
+}
+
+func TestWriters(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(io.Writer, *Report) error
+		want  string
+	}{
+		{
+			name:  "sitemap",
+			write: WriteSitemap,
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/</loc>
+  </url>
+  <url>
+    <loc>https://example.com/a?x=1&amp;y=2</loc>
+  </url>
+</urlset>
+`,
+		},
+		{
+			name:  "json",
+			write: WriteJSON,
+			want: `{
+  "pages": [
+    {
+      "url": "https://example.com/",
+      "depth": 0,
+      "links": [
+        "https://example.com/a?x=1&y=2",
+        "https://example.com/missing",
+        "https://other.example/"
+      ]
+    },
+    {
+      "url": "https://example.com/a?x=1&y=2",
+      "depth": 1,
+      "parent": "https://example.com/",
+      "links": [
+        "https://example.com/"
+      ]
+    },
+    {
+      "url": "https://example.com/missing",
+      "depth": 1,
+      "parent": "https://example.com/",
+      "error": "not found"
+    }
+  ]
+}
+`,
+		},
+		{
+			name:  "dot",
+			write: WriteDOT,
+			want: `digraph crawl {
+  "https://example.com/";
+  "https://example.com/a?x=1&y=2";
+  "https://example.com/missing" [color=red];
+  "https://example.com/" -> "https://example.com/a?x=1&y=2";
+  "https://example.com/" -> "https://example.com/missing";
+  "https://example.com/a?x=1&y=2" -> "https://example.com/";
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := tt.write(&b, writerReport); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.want, b.String())
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := newTestSite(t, testSitePages)
+	docs := newTestSite(t, map[string]string{
+		"/docs/":   `<a href="/docs/a">A</a><a href="/blog">Blog</a><a href="/">Home</a>`,
+		"/docs/a":  `A`,
+		"/blog":    `Blog`,
+		"/":        `Home`,
+		"/guide":   `<a href="/guide/a">A</a><a href="/guide-old/">Old</a>`,
+		"/guide/a": `A`,
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		contains []string
+		missing  []string
+		stderr   string
+	}{
+		{
+			name: "sitemap",
+			args: []string{srv.URL},
+			contains: []string{
+				"<loc>" + srv.URL + "/</loc>",
+				"<loc>" + srv.URL + "/docs/</loc>",
+				"<loc>" + srv.URL + "/blog</loc>",
+			},
+			missing: []string{"/missing", "other.example"},
+		},
+		{
+			name:     "depth",
+			args:     []string{"-depth", "1", srv.URL},
+			contains: []string{"<loc>" + srv.URL + "/</loc>"},
+			missing:  []string{"/docs/", "/blog"},
+		},
+		{
+			name:     "json",
+			args:     []string{"-format", "json", "-workers", "1", srv.URL + "/"},
+			contains: []string{`"url": "` + srv.URL + `/missing"`, `"error": "` + srv.URL + `/missing: 404 Not Found"`},
+		},
+		{
+			name:     "dot",
+			args:     []string{"-format", "dot", "-exclude", "*/blog", srv.URL},
+			contains: []string{`"` + srv.URL + `/" -> "` + srv.URL + `/docs/";`, `"` + srv.URL + `/blog" [color=red];`},
+			missing:  []string{`/blog" -> `},
+		},
+		{
+			name:     "prefix scope",
+			args:     []string{"-scope", "prefix", "-format", "json", srv.URL + "/docs/"},
+			contains: []string{`"url": "` + srv.URL + `/docs/v2/intro"`},
+			missing:  []string{`"url": "` + srv.URL + `/blog"`},
+		},
+		{
+			name:    "host scope drops other links",
+			args:    []string{"-format", "dot", srv.URL},
+			missing: []string{"other.example"},
+		},
+		{
+			name:     "prefix scope drops other links",
+			args:     []string{"-scope", "prefix", "-format", "dot", docs.URL + "/docs/"},
+			contains: []string{`"` + docs.URL + `/docs/" -> "` + docs.URL + `/docs/a";`},
+			missing:  []string{`/blog"`, `"` + docs.URL + `/"`, "color=red"},
+			stderr:   "crawled 2 pages, 0 failed",
+		},
+		{
+			name:     "prefix scope compares path segments",
+			args:     []string{"-scope", "prefix", "-format", "dot", docs.URL + "/guide"},
+			contains: []string{`"` + docs.URL + `/guide" -> "` + docs.URL + `/guide/a";`},
+			missing:  []string{"/guide-old/"},
+			stderr:   "crawled 2 pages, 0 failed",
+		},
+		{
+			name:     "include narrows prefix scope",
+			args:     []string{"-scope", "prefix", "-include", "*/docs/", "-include", "*/blog", "-format", "json", docs.URL + "/docs/"},
+			contains: []string{`"url": "` + docs.URL + `/docs/"`},
+			missing:  []string{`"url": "` + docs.URL + `/blog"`},
+			stderr:   "crawled 2 pages, 1 failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			if err := run(context.Background(), tt.args, &stdout, &stderr); err != nil {
+				t.Fatalf("Unexpected error %v, stderr: %s", err, stderr.String())
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(stdout.String(), s) {
+					t.Errorf("Expected output to contain %q, got:\n%s", s, stdout.String())
+				}
+			}
+			for _, s := range tt.missing {
+				if strings.Contains(stdout.String(), s) {
+					t.Errorf("Expected output not to contain %q, got:\n%s", s, stdout.String())
+				}
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("Expected stderr to contain %q, got %q", tt.stderr, stderr.String())
+			}
+		})
+	}
+
+	t.Run("output file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sitemap.xml")
+		var stdout, stderr strings.Builder
+		if err := run(context.Background(), []string{"-o", path, srv.URL}, &stdout, &stderr); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "<loc>"+srv.URL+"/</loc>") {
+			t.Errorf("Wrong sitemap:\n%s", data)
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("Expected empty standard output, got %q", stdout.String())
+		}
+	})
+
+	for _, args := range [][]string{
+		{},
+		{srv.URL, srv.URL},
+		{"-format", "csv", srv.URL},
+		{"-scope", "world", srv.URL},
+		{"ftp://example.com/"},
+		{srv.URL + "/missing"},
+	} {
+		var stdout, stderr strings.Builder
+		if err := run(context.Background(), args, &stdout, &stderr); err == nil {
+			t.Errorf("%q: expected error", args)
+		}
+	}
+}
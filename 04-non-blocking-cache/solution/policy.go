@@ -0,0 +1,281 @@
+package main
+
+import (
+	"container/heap"
+	"container/list"
+	"hash/maphash"
+)
+
+// Policy decides which entries a bounded Cache evicts.
+// Cache calls it under its lock, so it doesn't need to be safe for
+// concurrent use, but it must not be shared between caches.
+// Only stored results are tracked, requests in flight are never evicted.
+type Policy interface {
+	// Add records a new stored key.
+	Add(key string)
+	// Access records a hit of a stored key.
+	Access(key string)
+	// Remove forgets a key removed from the cache for another reason.
+	Remove(key string)
+	// Evict chooses a key to evict and forgets it.
+	// Returns false if there are no keys.
+	Evict() (string, bool)
+}
+
+// LRU evicts the least recently used key.
+type LRU struct {
+	// Front is the most recently used
+	order *list.List
+	elems map[string]*list.Element
+}
+
+func NewLRU() *LRU {
+	return &LRU{order: list.New(), elems: make(map[string]*list.Element)}
+}
+
+func (p *LRU) Add(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *LRU) Access(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *LRU) Remove(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *LRU) Evict() (string, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	key := p.order.Remove(e).(string)
+	delete(p.elems, key)
+	return key, true
+}
+
+func (p *LRU) len() int {
+	return p.order.Len()
+}
+
+// LFU evicts the least frequently used key.
+// Ties are broken by recency, the least recently used goes first.
+type LFU struct {
+	entries lfuHeap
+	keys    map[string]*lfuEntry
+	// clock orders accesses
+	clock uint64
+}
+
+type lfuEntry struct {
+	key   string
+	freq  uint64
+	last  uint64
+	index int
+}
+
+// lfuHeap implements heap.Interface, the root is the next victim.
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].last < h[j].last
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *lfuHeap) Push(x any) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+func NewLFU() *LFU {
+	return &LFU{keys: make(map[string]*lfuEntry)}
+}
+
+func (p *LFU) Add(key string) {
+	if _, ok := p.keys[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.clock++
+	e := &lfuEntry{key: key, freq: 1, last: p.clock}
+	p.keys[key] = e
+	heap.Push(&p.entries, e)
+}
+
+func (p *LFU) Access(key string) {
+	e, ok := p.keys[key]
+	if !ok {
+		return
+	}
+	p.clock++
+	e.freq++
+	e.last = p.clock
+	heap.Fix(&p.entries, e.index)
+}
+
+func (p *LFU) Remove(key string) {
+	if e, ok := p.keys[key]; ok {
+		heap.Remove(&p.entries, e.index)
+		delete(p.keys, key)
+	}
+}
+
+func (p *LFU) Evict() (string, bool) {
+	if len(p.entries) == 0 {
+		return "", false
+	}
+	e := heap.Pop(&p.entries).(*lfuEntry)
+	delete(p.keys, e.key)
+	return e.key, true
+}
+
+// tinyLFUWindow is the share of keys in the admission window
+const tinyLFUWindow = 0.01
+
+// TinyLFU is a W-TinyLFU policy. New keys get into a small LRU window.
+// When the window is full, its oldest key moves to the main LRU, and once
+// that is full too, it's admitted only if it was requested more often than
+// the main victim, so keys requested once don't push out popular ones.
+type TinyLFU struct {
+	window    *LRU
+	main      *LRU
+	windowCap int
+	mainCap   int
+	sketch    *countMinSketch
+}
+
+// capacity is the expected number of keys in the cache,
+// it sizes the window and the frequency sketch.
+func NewTinyLFU(capacity int) *TinyLFU {
+	capacity = max(capacity, 2)
+	windowCap := max(1, int(tinyLFUWindow*float64(capacity)))
+	return &TinyLFU{
+		window:    NewLRU(),
+		main:      NewLRU(),
+		windowCap: windowCap,
+		mainCap:   capacity - windowCap,
+		sketch:    newCountMinSketch(capacity),
+	}
+}
+
+func (p *TinyLFU) Add(key string) {
+	p.sketch.increment(key)
+	if _, ok := p.main.elems[key]; ok {
+		p.main.Access(key)
+		return
+	}
+	p.window.Add(key)
+	// While main has room, nothing competes
+	for p.window.len() > p.windowCap && p.main.len() < p.mainCap {
+		candidate, _ := p.window.Evict()
+		p.main.Add(candidate)
+	}
+}
+
+func (p *TinyLFU) Access(key string) {
+	p.sketch.increment(key)
+	p.window.Access(key)
+	p.main.Access(key)
+}
+
+func (p *TinyLFU) Remove(key string) {
+	p.window.Remove(key)
+	p.main.Remove(key)
+}
+
+func (p *TinyLFU) Evict() (string, bool) {
+	if p.window.len() <= p.windowCap || p.main.len() == 0 {
+		if key, ok := p.main.Evict(); ok {
+			return key, true
+		}
+		return p.window.Evict()
+	}
+
+	// Window is over its share, its candidate competes with the main victim
+	candidate, _ := p.window.Evict()
+	victim := p.main.order.Back().Value.(string)
+	if p.sketch.estimate(candidate) > p.sketch.estimate(victim) {
+		p.main.Remove(victim)
+		p.main.Add(candidate)
+		return victim, true
+	}
+	return candidate, true
+}
+
+// countMinSketch estimates key frequencies in fixed memory.
+// Counters are halved periodically, so old popularity fades.
+type countMinSketch struct {
+	rows  [4][]uint8
+	seeds [4]maphash.Seed
+	// additions since the last reset
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	s := &countMinSketch{resetAt: 10 * capacity}
+	width := max(16, capacity)
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+		s.seeds[i] = maphash.MakeSeed()
+	}
+	return s
+}
+
+func (s *countMinSketch) increment(key string) {
+	for i := range s.rows {
+		c := &s.rows[i][maphash.String(s.seeds[i], key)%uint64(len(s.rows[i]))]
+		if *c < 15 {
+			*c++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	est := uint8(15)
+	for i := range s.rows {
+		est = min(est, s.rows[i][maphash.String(s.seeds[i], key)%uint64(len(s.rows[i]))])
+	}
+	return est
+}
+
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	s.additions /= 2
+}
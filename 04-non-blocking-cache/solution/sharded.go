@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"hash/maphash"
+	"runtime"
+	"time"
+)
+
+// ShardedOptions configures a ShardedCache. The fields are the same
+// as in Options, except Policy, because a policy can't be shared between shards.
+type ShardedOptions struct {
+	// ErrorTTL, FreshTTL and HardTTL are the same as in Options.
+	ErrorTTL time.Duration
+	FreshTTL time.Duration
+	HardTTL  time.Duration
+	// MaxEntries and MaxBytes are split between shards.
+	// A shard doesn't store values larger than its share of MaxBytes.
+	MaxEntries int
+	MaxBytes   int64
+	// MaxValueBytes is the size of the largest value to store.
+	// There are no more shards than MaxBytes/MaxValueBytes,
+	// so every shard can store such a value. Zero means no cap.
+	MaxValueBytes int64
+	// Shards is the number of shards. Defaults to 4 per CPU.
+	// There are no more shards than MaxEntries or MaxBytes,
+	// so every shard gets a limit.
+	Shards int
+	// NewPolicy returns eviction policy of a shard, capacity is MaxEntries of the shard.
+	// Defaults to LRU.
+	NewPolicy func(capacity int) Policy
+}
+
+// ShardedCache is a Cache split into shards with their own locks,
+// so callers of different addresses rarely wait for each other.
+// Requests for the same address go to the same shard,
+// so they are still fetched once.
+type ShardedCache struct {
+	shards []*Cache
+	seed   maphash.Seed
+}
+
+func NewShardedCache(client Client, opts ShardedOptions) *ShardedCache {
+	n := opts.Shards
+	if n <= 0 {
+		n = 4 * runtime.GOMAXPROCS(0)
+	}
+	// A shard limit of zero means no limit, so every shard needs at least one
+	if opts.MaxEntries > 0 {
+		n = min(n, opts.MaxEntries)
+	}
+	if opts.MaxBytes > 0 {
+		n = int(min(int64(n), opts.MaxBytes/max(opts.MaxValueBytes, 1)))
+		// Values larger than MaxBytes are not stored at all, so one shard is enough
+		n = max(n, 1)
+	}
+
+	c := &ShardedCache{shards: make([]*Cache, n), seed: maphash.MakeSeed()}
+	for i := range c.shards {
+		shardOpts := Options{
+			ErrorTTL:   opts.ErrorTTL,
+			MaxEntries: split(opts.MaxEntries, n, i),
+			MaxBytes:   split(opts.MaxBytes, n, i),
+			FreshTTL:   opts.FreshTTL,
+			HardTTL:    opts.HardTTL,
+		}
+		if opts.NewPolicy != nil {
+			shardOpts.Policy = opts.NewPolicy(shardOpts.MaxEntries)
+		}
+		c.shards[i] = NewCacheWithOptions(client, shardOpts)
+	}
+	return c
+}
+
+// split returns the share of limit for shard i of n.
+// The remainder goes to the first shards, so shares add up to limit.
+func split[T int | int64](limit T, n, i int) T {
+	if limit <= 0 {
+		return limit
+	}
+	share := limit / T(n)
+	if T(i) < limit%T(n) {
+		share++
+	}
+	return share
+}
+
+func (c *ShardedCache) Get(address string) (string, error) {
+	return c.shard(address).Get(address)
+}
+
+// GetContext is Cache.GetContext on the shard of address.
+func (c *ShardedCache) GetContext(ctx context.Context, address string) (string, error) {
+	return c.shard(address).GetContext(ctx, address)
+}
+
+func (c *ShardedCache) shard(address string) *Cache {
+	return c.shards[maphash.String(c.seed, address)%uint64(len(c.shards))]
+}
@@ -1,44 +1,265 @@
 package main
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
 type Client interface {
 	Get(address string) (string, error)
 }
 
+// ContextClient is a Client that can be cancelled.
+// Cache uses it to cancel fetches nobody waits for.
+type ContextClient interface {
+	Client
+	GetContext(ctx context.Context, address string) (string, error)
+}
+
+// Options configures a Cache.
+type Options struct {
+	// ErrorTTL is how long an error is returned without calling Client.Get again.
+	// Zero means an error is shared only with callers waiting for it.
+	ErrorTTL time.Duration
+	// MaxEntries is the maximum number of stored results. Zero means no limit.
+	MaxEntries int
+	// MaxBytes is the maximum total size of stored values. Zero means no limit.
+	// Values larger than that are returned, but not stored.
+	MaxBytes int64
+	// Policy chooses entries to evict when the cache is bounded.
+	// Defaults to LRU.
+	Policy Policy
+	// FreshTTL is how long a value is fresh. A stale value is returned right away,
+	// while a single background refresh per address fetches a new one.
+	// Zero means values never go stale.
+	FreshTTL time.Duration
+	// HardTTL is how long a value is returned at all. After that,
+	// callers wait for a new fetch. Zero means values never expire.
+	HardTTL time.Duration
+}
+
 type task struct {
 	body  string
 	err   error
+	done  bool
 	ready chan struct{}
+	// expires is when the task is evicted, zero means never
+	expires time.Time
+	// stale is when a successful task needs a refresh, zero means never
+	stale      time.Time
+	refreshing bool
+	// waiters is the number of callers waiting for the task.
+	// When the last one gives up, the fetch is cancelled
+	waiters int
+	cancel  context.CancelFunc
+	// stored is true if the result is tracked by the eviction policy
+	stored bool
 }
 
 type Cache struct {
 	client Client
+	opts   Options
 	m      map[string]*task
+	// entries and bytes are the size of stored results
+	entries int
+	bytes   int64
 	sync.Mutex
 }
 
 func NewCache(client Client) *Cache {
-	return &Cache{client: client, m: make(map[string]*task)}
+	return NewCacheWithOptions(client, Options{})
+}
+
+func NewCacheWithOptions(client Client, opts Options) *Cache {
+	if opts.Policy == nil && opts.bounded() {
+		opts.Policy = NewLRU()
+	}
+	return &Cache{client: client, opts: opts, m: make(map[string]*task)}
 }
 
 func (c *Cache) Get(address string) (string, error) {
+	return c.GetContext(context.Background(), address)
+}
+
+// GetContext is Get that stops waiting when ctx is done.
+// The fetch is shared by all callers of the address and is cancelled
+// only when every one of them has given up. A fetch of a Client that
+// is not a ContextClient can't be cancelled, so later callers join it.
+func (c *Cache) GetContext(ctx context.Context, address string) (string, error) {
 	c.Lock()
 	t := c.m[address]
+	// Result fields are written under the lock, so they can be read here.
+	// Expired task is forgotten, so the caller waits for a new fetch
+	now := time.Now()
+	if t != nil && t.done && !t.expires.IsZero() && !now.Before(t.expires) {
+		c.remove(address, t)
+		t = nil
+	}
+	if t != nil && t.stored {
+		c.opts.Policy.Access(address)
+	}
+	// Stale value is still returned, only the first caller starts a refresh
+	if t != nil && t.done && !t.stale.IsZero() && !now.Before(t.stale) && !t.refreshing {
+		t.refreshing = true
+		go c.refresh(context.WithoutCancel(ctx), address, t)
+	}
 	if t == nil {
-		t = &task{ready: make(chan struct{})}
+		// The fetch doesn't belong to the first caller, it can leave
+		// as any other, but context values are kept
+		fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		t = &task{ready: make(chan struct{}), cancel: cancel}
 		c.m[address] = t
-		c.Unlock()
+		go c.fetch(fetchCtx, address, t)
+	}
+	t.waiters++
+	c.Unlock()
 
-		t.body, t.err = c.client.Get(address)
-		close(t.ready)
+	select {
+	case <-t.ready:
 		return t.body, t.err
-	} else {
-		c.Unlock()
+	case <-ctx.Done():
+	}
 
-		<-t.ready
-		return t.body, t.err
+	c.Lock()
+	defer c.Unlock()
+	t.waiters--
+	if t.waiters == 0 && !t.done {
+		// Nobody waits for the result, so the fetch is forgotten
+		// and the next caller starts a new one. A fetch that can't be
+		// cancelled keeps running, so it stays for the next caller to join
+		t.cancel()
+		if _, ok := c.client.(ContextClient); ok && c.m[address] == t {
+			delete(c.m, address)
+		}
+	}
+	return "", ctx.Err()
+}
+
+func (c *Cache) fetch(ctx context.Context, address string, t *task) {
+	defer t.cancel()
+	body, err := c.get(ctx, address)
+
+	c.Lock()
+	t.body, t.err, t.done = body, err, true
+	// Task is not in the map anymore if every waiter has given up
+	if c.m[address] == t {
+		switch {
+		case err != nil && c.opts.ErrorTTL <= 0:
+			delete(c.m, address)
+		case err != nil:
+			t.expires = time.Now().Add(c.opts.ErrorTTL)
+			c.store(address, t)
+		default:
+			c.setTTL(t)
+			c.store(address, t)
+		}
+	}
+	c.Unlock()
+	close(t.ready)
+}
+
+// refresh fetches a new value for a stale task and replaces it.
+// If the fetch fails, the stale value stays and the next caller retries.
+func (c *Cache) refresh(ctx context.Context, address string, t *task) {
+	body, err := c.get(ctx, address)
+
+	c.Lock()
+	defer c.Unlock()
+	t.refreshing = false
+	// Task could be evicted or expired meanwhile
+	if err != nil || c.m[address] != t {
+		return
+	}
+	// Value too large to store is dropped as a new one would be,
+	// without evicting anything else for it
+	if t.stored && c.opts.MaxBytes > 0 && int64(len(body)) > c.opts.MaxBytes {
+		c.remove(address, t)
+		return
+	}
+
+	// Callers read the result of a ready task without the lock,
+	// so the task is replaced instead of updated
+	fresh := &task{body: body, done: true, ready: make(chan struct{})}
+	close(fresh.ready)
+	c.setTTL(fresh)
+	c.m[address] = fresh
+	if t.stored {
+		t.stored, fresh.stored = false, true
+		c.bytes += int64(len(fresh.body)) - int64(len(t.body))
+		c.shrink()
+	}
+}
+
+func (c *Cache) get(ctx context.Context, address string) (string, error) {
+	if client, ok := c.client.(ContextClient); ok {
+		return client.GetContext(ctx, address)
+	}
+	return c.client.Get(address)
+}
+
+func (c *Cache) setTTL(t *task) {
+	now := time.Now()
+	if c.opts.FreshTTL > 0 {
+		t.stale = now.Add(c.opts.FreshTTL)
+	}
+	if c.opts.HardTTL > 0 {
+		t.expires = now.Add(c.opts.HardTTL)
+	}
+}
+
+func (o Options) bounded() bool {
+	return o.MaxEntries > 0 || o.MaxBytes > 0
+}
+
+// store adds a finished task to the eviction policy
+// and shrinks the cache.
+// Tasks in flight are not in the policy, so they are never evicted.
+func (c *Cache) store(address string, t *task) {
+	if !c.opts.bounded() {
+		return
+	}
+	size := int64(len(t.body))
+	if c.opts.MaxBytes > 0 && size > c.opts.MaxBytes {
+		delete(c.m, address)
+		return
+	}
+
+	t.stored = true
+	c.entries++
+	c.bytes += size
+	c.opts.Policy.Add(address)
+	c.shrink()
+}
+
+// shrink evicts entries until the cache fits its limits.
+func (c *Cache) shrink() {
+	for (c.opts.MaxEntries > 0 && c.entries > c.opts.MaxEntries) || (c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes) {
+		victim, ok := c.opts.Policy.Evict()
+		if !ok {
+			break
+		}
+		c.evict(victim)
+	}
+}
+
+// evict removes an entry chosen by the policy.
+func (c *Cache) evict(address string) {
+	t := c.m[address]
+	if t == nil || !t.stored {
+		return
+	}
+	delete(c.m, address)
+	t.stored = false
+	c.entries--
+	c.bytes -= int64(len(t.body))
+}
+
+// remove removes an expired entry.
+func (c *Cache) remove(address string, t *task) {
+	if t.stored {
+		c.opts.Policy.Remove(address)
+		c.evict(address)
 	}
+	delete(c.m, address)
 }
@@ -1,9 +1,107 @@
 package main
 
+import (
+	"context"
+	"time"
+)
+
 type Client interface {
 	Get(address string) (string, error)
 }
 
+// ContextClient is a Client that can be cancelled.
+// Cache uses it to cancel fetches nobody waits for.
+type ContextClient interface {
+	Client
+	GetContext(ctx context.Context, address string) (string, error)
+}
+
+// Options configures a Cache.
+type Options struct {
+	// ErrorTTL is how long an error is returned without calling Client.Get again.
+	// Zero means an error is shared only with callers waiting for it.
+	ErrorTTL time.Duration
+	// MaxEntries is the maximum number of stored results. Zero means no limit.
+	MaxEntries int
+	// MaxBytes is the maximum total size of stored values. Zero means no limit.
+	// Values larger than that are returned, but not stored.
+	MaxBytes int64
+	// Policy chooses entries to evict when the cache is bounded.
+	// Defaults to LRU.
+	Policy Policy
+	// FreshTTL is how long a value is fresh. A stale value is returned right away,
+	// while a single background refresh per address fetches a new one.
+	// Zero means values never go stale.
+	FreshTTL time.Duration
+	// HardTTL is how long a value is returned at all. After that,
+	// callers wait for a new fetch. Zero means values never expire.
+	HardTTL time.Duration
+}
+
+// Policy decides which entries a bounded Cache evicts.
+// Cache calls it under its lock, so it doesn't need to be safe for
+// concurrent use, but it must not be shared between caches.
+// Only stored results are tracked, requests in flight are never evicted.
+type Policy interface {
+	// Add records a new stored key.
+	Add(key string)
+	// Access records a hit of a stored key.
+	Access(key string)
+	// Remove forgets a key removed from the cache for another reason.
+	Remove(key string)
+	// Evict chooses a key to evict and forgets it.
+	// Returns false if there are no keys.
+	Evict() (string, bool)
+}
+
+// LRU evicts the least recently used key.
+type LRU struct {
+	// TODO: Implement
+}
+
+func NewLRU() *LRU {
+	return &LRU{}
+}
+
+func (p *LRU) Add(key string)        {}
+func (p *LRU) Access(key string)     {}
+func (p *LRU) Remove(key string)     {}
+func (p *LRU) Evict() (string, bool) { return "", false }
+
+// LFU evicts the least frequently used key.
+// Ties are broken by recency, the least recently used goes first.
+type LFU struct {
+	// TODO: Implement
+}
+
+func NewLFU() *LFU {
+	return &LFU{}
+}
+
+func (p *LFU) Add(key string)        {}
+func (p *LFU) Access(key string)     {}
+func (p *LFU) Remove(key string)     {}
+func (p *LFU) Evict() (string, bool) { return "", false }
+
+// TinyLFU is a W-TinyLFU policy. New keys get into a small LRU window.
+// When the window is full, its oldest key moves to the main LRU, and once
+// that is full too, it's admitted only if it was requested more often than
+// the main victim, so keys requested once don't push out popular ones.
+type TinyLFU struct {
+	// TODO: Implement
+}
+
+// capacity is the expected number of keys in the cache,
+// it sizes the window and the frequency sketch.
+func NewTinyLFU(capacity int) *TinyLFU {
+	return &TinyLFU{}
+}
+
+func (p *TinyLFU) Add(key string)        {}
+func (p *TinyLFU) Access(key string)     {}
+func (p *TinyLFU) Remove(key string)     {}
+func (p *TinyLFU) Evict() (string, bool) { return "", false }
+
 type Cache struct {
 	client Client
 	// You can add new fields if needed
@@ -15,8 +113,76 @@ func NewCache(client Client) *Cache {
 	return &Cache{client: client}
 }
 
-// Cache Client.Get result
+func NewCacheWithOptions(client Client, opts Options) *Cache {
+	// TODO: Implement
+	return &Cache{client: client}
+}
+
+// Cache Client.Get result.
+// Errors are shared with callers waiting for them and evicted after Options.ErrorTTL,
+// so a transient failure doesn't stay forever
 func (c *Cache) Get(address string) (string, error) {
 	// TODO: Implement. Right now it doesn't cache
 	return c.client.Get(address)
 }
+
+// GetContext is Get that stops waiting when ctx is done.
+// The fetch is shared by all callers of the address and is cancelled
+// only when every one of them has given up. A fetch of a Client that
+// is not a ContextClient can't be cancelled, so later callers join it.
+func (c *Cache) GetContext(ctx context.Context, address string) (string, error) {
+	// TODO: Implement
+	return c.Get(address)
+}
+
+// ShardedOptions configures a ShardedCache. The fields are the same
+// as in Options, except Policy, because a policy can't be shared between shards.
+type ShardedOptions struct {
+	// ErrorTTL, FreshTTL and HardTTL are the same as in Options.
+	ErrorTTL time.Duration
+	FreshTTL time.Duration
+	HardTTL  time.Duration
+	// MaxEntries and MaxBytes are split between shards.
+	// A shard doesn't store values larger than its share of MaxBytes.
+	MaxEntries int
+	MaxBytes   int64
+	// MaxValueBytes is the size of the largest value to store.
+	// There are no more shards than MaxBytes/MaxValueBytes,
+	// so every shard can store such a value. Zero means no cap.
+	MaxValueBytes int64
+	// Shards is the number of shards. Defaults to 4 per CPU.
+	// There are no more shards than MaxEntries or MaxBytes,
+	// so every shard gets a limit.
+	Shards int
+	// NewPolicy returns eviction policy of a shard, capacity is MaxEntries of the shard.
+	// Defaults to LRU.
+	NewPolicy func(capacity int) Policy
+}
+
+// ShardedCache is a Cache split into shards with their own locks,
+// so callers of different addresses rarely wait for each other.
+// Requests for the same address go to the same shard,
+// so they are still fetched once.
+type ShardedCache struct {
+	// TODO: Implement
+	cache *Cache
+}
+
+func NewShardedCache(client Client, opts ShardedOptions) *ShardedCache {
+	return &ShardedCache{cache: NewCacheWithOptions(client, Options{
+		ErrorTTL:   opts.ErrorTTL,
+		MaxEntries: opts.MaxEntries,
+		MaxBytes:   opts.MaxBytes,
+		FreshTTL:   opts.FreshTTL,
+		HardTTL:    opts.HardTTL,
+	})}
+}
+
+func (c *ShardedCache) Get(address string) (string, error) {
+	return c.cache.Get(address)
+}
+
+// GetContext is Cache.GetContext on the shard of address.
+func (c *ShardedCache) GetContext(ctx context.Context, address string) (string, error) {
+	return c.cache.GetContext(ctx, address)
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -94,15 +100,17 @@ func TestGet(t *testing.T) {
 			responses: map[string][]response{
 				"example.com": {
 					{body: "", err: ErrExpected, delay: 50 * time.Millisecond},
+					{body: "response1", err: nil, delay: 50 * time.Millisecond},
 				},
 			},
-			requests: []string{"example.com", "example.com"},
+			requests: []string{"example.com", "example.com", "example.com"},
 			results: []struct {
 				body string
 				err  error
 			}{
 				{body: "", err: ErrExpected},
-				{body: "", err: ErrExpected},
+				{body: "response1", err: nil},
+				{body: "response1", err: nil},
 			},
 		},
 		{
@@ -134,7 +142,7 @@ func TestGet(t *testing.T) {
 				{body: "success", err: nil},
 				{body: "", err: ErrExpected},
 				{body: "success", err: nil},
-				{body: "", err: ErrExpected},
+				{body: "", err: ErrNoResponse},
 			},
 		},
 		{
@@ -174,3 +182,668 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestGetErrorShared(t *testing.T) {
+	client := newMockClient(map[string][]response{
+		"example.com": {
+			{body: "", err: ErrExpected, delay: 100 * time.Millisecond},
+			{body: "response1", err: nil, delay: 10 * time.Millisecond},
+		},
+	})
+	cache := NewCache(client)
+
+	// Callers waiting for the failed request share its error
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Get("example.com"); err != ErrExpected {
+				t.Errorf("Expected %v, got %v", ErrExpected, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The next caller retries
+	for i := 0; i < 2; i++ {
+		if resp, err := cache.Get("example.com"); err != nil || resp != "response1" {
+			t.Errorf("Expected response1, got %q, %v", resp, err)
+		}
+	}
+}
+
+func TestGetErrorTTL(t *testing.T) {
+	client := newMockClient(map[string][]response{
+		"example.com": {
+			{body: "", err: ErrExpected},
+			{body: "response1", err: nil},
+		},
+	})
+	cache := NewCacheWithOptions(client, Options{ErrorTTL: 100 * time.Millisecond})
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get("example.com"); err != ErrExpected {
+			t.Errorf("Expected cached %v, got %v", ErrExpected, err)
+		}
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if resp, err := cache.Get("example.com"); err != nil || resp != "response1" {
+		t.Errorf("Expected response1 after TTL, got %q, %v", resp, err)
+	}
+}
+
+// ctxClient is a ContextClient that takes delay for every request,
+// unless ctx is done earlier. It counts calls and cancellations.
+type ctxClient struct {
+	delay     time.Duration
+	mu        sync.Mutex
+	calls     int
+	cancelled int
+}
+
+func (c *ctxClient) Get(address string) (string, error) {
+	return c.GetContext(context.Background(), address)
+}
+
+func (c *ctxClient) GetContext(ctx context.Context, address string) (string, error) {
+	c.mu.Lock()
+	c.calls++
+	n := c.calls
+	c.mu.Unlock()
+
+	select {
+	case <-time.After(c.delay):
+		return fmt.Sprintf("response%d", n), nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.cancelled++
+		c.mu.Unlock()
+		return "", ctx.Err()
+	}
+}
+
+func (c *ctxClient) stats() (calls, cancelled int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls, c.cancelled
+}
+
+func TestGetContextWaiterLeaves(t *testing.T) {
+	client := &ctxClient{delay: 100 * time.Millisecond}
+	cache := NewCache(client)
+
+	// The first caller leaves, the second still gets the shared result
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if resp, err := cache.Get("example.com"); err != nil || resp != "response1" {
+			t.Errorf("Expected response1, got %q, %v", resp, err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := cache.GetContext(ctx, "example.com"); err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 60*time.Millisecond {
+		t.Errorf("Expected to return on deadline, took %v", elapsed)
+	}
+
+	<-done
+	if calls, cancelled := client.stats(); calls != 1 || cancelled != 0 {
+		t.Errorf("Expected 1 call and no cancellations, got %d and %d", calls, cancelled)
+	}
+}
+
+func TestGetContextAllWaitersLeave(t *testing.T) {
+	client := &ctxClient{delay: 100 * time.Millisecond}
+	cache := NewCache(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.GetContext(ctx, "example.com"); err != context.Canceled {
+				t.Errorf("Expected %v, got %v", context.Canceled, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	// Give the fetch a moment to see the cancellation
+	time.Sleep(20 * time.Millisecond)
+	if calls, cancelled := client.stats(); calls != 1 || cancelled != 1 {
+		t.Errorf("Expected 1 cancelled call, got %d calls and %d cancellations", calls, cancelled)
+	}
+
+	// Cancelled fetch is not cached
+	if resp, err := cache.Get("example.com"); err != nil || resp != "response2" {
+		t.Errorf("Expected response2, got %q, %v", resp, err)
+	}
+}
+
+func TestGetContextClient(t *testing.T) {
+	// Client without context can't be cancelled, but callers still leave
+	// and the next one joins the same fetch
+	client := newMockClient(map[string][]response{
+		"example.com": {
+			{body: "response1", err: nil, delay: 100 * time.Millisecond},
+			{body: "response2", err: nil},
+		},
+	})
+	cache := NewCache(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := cache.GetContext(ctx, "example.com"); err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 60*time.Millisecond {
+		t.Errorf("Expected to return on deadline, took %v", elapsed)
+	}
+
+	if resp, err := cache.GetContext(context.Background(), "example.com"); err != nil || resp != "response1" {
+		t.Errorf("Expected response1, got %q, %v", resp, err)
+	}
+	if left := len(client.responses["example.com"]); left != 1 {
+		t.Errorf("Expected a single Client.Get call, got %d", 2-left)
+	}
+}
+
+// countingClient returns bodies[address], or address itself, and counts calls.
+type countingClient struct {
+	bodies map[string]string
+	mu     sync.Mutex
+	calls  int
+}
+
+func (c *countingClient) Get(address string) (string, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	if body, ok := c.bodies[address]; ok {
+		return body, nil
+	}
+	return address, nil
+}
+
+func (c *countingClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func TestBoundedCache(t *testing.T) {
+	// One-hit keys c, d and e scan through the cache after popular a and b
+	scan := strings.Split("a a a b b b c d e a b", " ")
+	tests := []struct {
+		name     string
+		opts     Options
+		bodies   map[string]string
+		requests []string
+		// hits is "h" for a hit and "m" for a miss for every request
+		hits string
+	}{
+		{
+			name:     "unbounded",
+			requests: scan,
+			hits:     "mhhmhhmmmhh",
+		},
+		{
+			name:     "LRU by default",
+			opts:     Options{MaxEntries: 2},
+			requests: strings.Split("a b a c a b", " "),
+			hits:     "mmhmhm",
+		},
+		{
+			name:     "LRU scan",
+			opts:     Options{MaxEntries: 3, Policy: NewLRU()},
+			requests: scan,
+			hits:     "mhhmhhmmmmm",
+		},
+		{
+			name:     "LFU",
+			opts:     Options{MaxEntries: 2, Policy: NewLFU()},
+			requests: strings.Split("a a b c a b", " "),
+			hits:     "mhmmhm",
+		},
+		{
+			name:     "LFU scan",
+			opts:     Options{MaxEntries: 3, Policy: NewLFU()},
+			requests: scan,
+			hits:     "mhhmhhmmmhh",
+		},
+		{
+			name:     "TinyLFU scan",
+			opts:     Options{MaxEntries: 3, Policy: NewTinyLFU(3)},
+			requests: scan,
+			hits:     "mhhmhhmmmhh",
+		},
+		{
+			name:     "bytes",
+			opts:     Options{MaxBytes: 10},
+			bodies:   map[string]string{"a": "1234", "b": "1234", "c": "1234"},
+			requests: strings.Split("a b a c a b", " "),
+			hits:     "mmhmhm",
+		},
+		{
+			name:     "value larger than MaxBytes is not stored",
+			opts:     Options{MaxBytes: 10},
+			bodies:   map[string]string{"big": "12345678901"},
+			requests: strings.Split("a big a big", " "),
+			hits:     "mmhm",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingClient{bodies: tt.bodies}
+			cache := NewCacheWithOptions(client, tt.opts)
+			var hits strings.Builder
+			for _, req := range tt.requests {
+				calls := client.count()
+				resp, err := cache.Get(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want, ok := tt.bodies[req]; ok && resp != want || !ok && resp != req {
+					t.Errorf("Wrong response for %s: %q", req, resp)
+				}
+				if client.count() == calls {
+					hits.WriteString("h")
+				} else {
+					hits.WriteString("m")
+				}
+			}
+			if hits.String() != tt.hits {
+				t.Errorf("Expected hits %s, got %s", tt.hits, hits.String())
+			}
+		})
+	}
+}
+
+func TestBoundedCacheInFlight(t *testing.T) {
+	client := newMockClient(map[string][]response{
+		"slow.com": {{body: "slow", delay: 100 * time.Millisecond}},
+		"a.com":    {{body: "a"}},
+		"b.com":    {{body: "b"}},
+		"c.com":    {{body: "c"}},
+	})
+	cache := NewCacheWithOptions(client, Options{MaxEntries: 1})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := cache.Get("slow.com"); err != nil || resp != "slow" {
+				t.Errorf("Expected slow, got %q, %v", resp, err)
+			}
+		}()
+	}
+
+	// Cache is full of finished entries, the one in flight stays
+	time.Sleep(20 * time.Millisecond)
+	for _, address := range []string{"a.com", "b.com", "c.com"} {
+		if _, err := cache.Get(address); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	// slow.com is the newest, so it stays after its fetch
+	if resp, err := cache.Get("slow.com"); err != nil || resp != "slow" {
+		t.Errorf("Expected cached slow, got %q, %v", resp, err)
+	}
+}
+
+// BenchmarkHitRatio reports hit ratio of policies on Zipf distributed keys.
+func BenchmarkHitRatio(b *testing.B) {
+	const keys = 100_000
+	policies := []struct {
+		name   string
+		policy func(capacity int) Policy
+	}{
+		{"lru", func(int) Policy { return NewLRU() }},
+		{"lfu", func(int) Policy { return NewLFU() }},
+		{"tinylfu", func(capacity int) Policy { return NewTinyLFU(capacity) }},
+	}
+
+	for _, capacity := range []int{100, 1000, 10_000} {
+		for _, p := range policies {
+			b.Run(fmt.Sprintf("%s/%d", p.name, capacity), func(b *testing.B) {
+				zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.01, 1, keys-1)
+				requests := make([]string, 1<<16)
+				for i := range requests {
+					requests[i] = strconv.FormatUint(zipf.Uint64(), 10)
+				}
+
+				client := &countingClient{}
+				cache := NewCacheWithOptions(client, Options{MaxEntries: capacity, Policy: p.policy(capacity)})
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					cache.Get(requests[i%len(requests)])
+				}
+				b.ReportMetric(1-float64(client.count())/float64(b.N), "hit-ratio")
+			})
+		}
+	}
+}
+
+func TestShardedCache(t *testing.T) {
+	const keys, callers = 100, 10
+	client := &ctxClient{delay: 20 * time.Millisecond}
+	cache := NewShardedCache(client, ShardedOptions{Shards: 8})
+
+	// Concurrent callers of the same address share one fetch
+	var wg sync.WaitGroup
+	results := make([][]string, keys)
+	for k := 0; k < keys; k++ {
+		results[k] = make([]string, callers)
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := cache.Get(fmt.Sprintf("example%d.com", k))
+				if err != nil {
+					t.Error(err)
+				}
+				results[k][i] = resp
+			}()
+		}
+	}
+	wg.Wait()
+
+	if calls, _ := client.stats(); calls != keys {
+		t.Errorf("Expected %d calls, got %d", keys, calls)
+	}
+	for k, resps := range results {
+		for _, resp := range resps {
+			if resp != resps[0] {
+				t.Errorf("Callers of key %d got different responses: %q", k, resps)
+				break
+			}
+		}
+	}
+}
+
+func TestShardedCacheOptions(t *testing.T) {
+	client := newMockClient(map[string][]response{
+		"example.com": {
+			{body: "", err: ErrExpected},
+			{body: "response1", err: nil},
+		},
+	})
+	cache := NewShardedCache(client, ShardedOptions{Shards: 4})
+
+	// Errors are evicted as in Cache
+	if _, err := cache.Get("example.com"); err != ErrExpected {
+		t.Errorf("Expected %v, got %v", ErrExpected, err)
+	}
+	if resp, err := cache.Get("example.com"); err != nil || resp != "response1" {
+		t.Errorf("Expected response1, got %q, %v", resp, err)
+	}
+
+	// Options are passed to every shard
+	client = newMockClient(map[string][]response{
+		"example.com": {
+			{body: "", err: ErrExpected},
+			{body: "response1", err: nil},
+		},
+	})
+	cache = NewShardedCache(client, ShardedOptions{Shards: 4, ErrorTTL: time.Minute})
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get("example.com"); err != ErrExpected {
+			t.Errorf("Expected cached %v, got %v", ErrExpected, err)
+		}
+	}
+
+	// Limits are split between shards, the total is MaxEntries
+	counting := &countingClient{}
+	var policies int
+	bounded := NewShardedCache(counting, ShardedOptions{
+		MaxEntries: 40,
+		Shards:     4,
+		NewPolicy: func(capacity int) Policy {
+			policies++
+			if capacity != 10 {
+				t.Errorf("Expected shard capacity 10, got %d", capacity)
+			}
+			return NewLFU()
+		},
+	})
+	if policies != 4 {
+		t.Errorf("Expected a policy per shard, got %d", policies)
+	}
+	for i := 0; i < 1000; i++ {
+		bounded.Get(strconv.Itoa(i))
+	}
+	calls := counting.count()
+	for i := 0; i < 1000; i++ {
+		bounded.Get(strconv.Itoa(i))
+	}
+	if stored := 1000 - (counting.count() - calls); stored > 40 {
+		t.Errorf("Expected at most 40 stored entries, got %d", stored)
+	}
+
+	// Every shard gets a limit, so there are no more shards than entries
+	for _, tt := range []struct{ maxEntries, shards int }{{10, 64}, {41, 4}} {
+		var capacity int
+		counting := &countingClient{}
+		bounded := NewShardedCache(counting, ShardedOptions{
+			MaxEntries: tt.maxEntries,
+			Shards:     tt.shards,
+			NewPolicy: func(c int) Policy {
+				capacity += c
+				return NewLRU()
+			},
+		})
+		if capacity != tt.maxEntries {
+			t.Errorf("Expected shard capacities to add up to %d, got %d", tt.maxEntries, capacity)
+		}
+		for i := 0; i < 1000; i++ {
+			bounded.Get(strconv.Itoa(i))
+		}
+		calls := counting.count()
+		for i := 0; i < 1000; i++ {
+			bounded.Get(strconv.Itoa(i))
+		}
+		if stored := 1000 - (counting.count() - calls); stored > tt.maxEntries {
+			t.Errorf("Expected at most %d stored entries, got %d", tt.maxEntries, stored)
+		}
+	}
+	// Value is larger than MaxBytes of 16 shards, but fits MaxBytes of 5
+	for _, tt := range []struct {
+		maxValueBytes int64
+		calls         int
+	}{{0, 3}, {200, 1}} {
+		counting := &countingClient{bodies: map[string]string{"large": strings.Repeat("x", 200)}}
+		bounded := NewShardedCache(counting, ShardedOptions{MaxBytes: 1000, MaxValueBytes: tt.maxValueBytes, Shards: 16})
+		for i := 0; i < 3; i++ {
+			bounded.Get("large")
+		}
+		if calls := counting.count(); calls != tt.calls {
+			t.Errorf("MaxValueBytes %d: expected %d fetches, got %d", tt.maxValueBytes, tt.calls, calls)
+		}
+	}
+}
+
+// BenchmarkGetParallel compares a single lock with shards under contention.
+// Run it with -cpu to see how it scales.
+func BenchmarkGetParallel(b *testing.B) {
+	type getter interface {
+		Get(address string) (string, error)
+	}
+	caches := []struct {
+		name string
+		new  func(Client) getter
+	}{
+		{"mutex", func(c Client) getter { return NewCache(c) }},
+		{"sharded", func(c Client) getter { return NewShardedCache(c, ShardedOptions{}) }},
+	}
+
+	for _, keys := range []int{1, 1024} {
+		addresses := make([]string, keys)
+		for i := range addresses {
+			addresses[i] = fmt.Sprintf("example%d.com", i)
+		}
+
+		for _, c := range caches {
+			b.Run(fmt.Sprintf("%s/keys=%d", c.name, keys), func(b *testing.B) {
+				cache := c.new(&countingClient{})
+				// Every request is a hit, so only locking is measured
+				for _, address := range addresses {
+					cache.Get(address)
+				}
+
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := rand.Intn(keys)
+					for pb.Next() {
+						cache.Get(addresses[i%keys])
+						i++
+					}
+				})
+			})
+		}
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	client := newMockClient(map[string][]response{
+		"example.com": {
+			{body: "response1"},
+			{body: "response2", delay: 100 * time.Millisecond},
+		},
+	})
+	cache := NewCacheWithOptions(client, Options{FreshTTL: 50 * time.Millisecond})
+
+	if resp, _ := cache.Get("example.com"); resp != "response1" {
+		t.Fatalf("Expected response1, got %q", resp)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	// Stale value is returned right away, while the only refresh runs.
+	// Mock client has a single response left, so a second refresh would fail
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := cache.Get("example.com"); err != nil || resp != "response1" {
+				t.Errorf("Expected stale response1, got %q, %v", resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected stale value right away, took %v", elapsed)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if resp, err := cache.Get("example.com"); err != nil || resp != "response2" {
+		t.Errorf("Expected refreshed response2, got %q, %v", resp, err)
+	}
+}
+
+func TestStaleRefreshError(t *testing.T) {
+	client := newMockClient(map[string][]response{
+		"example.com": {
+			{body: "response1"},
+			{body: "", err: ErrExpected},
+			{body: "response2"},
+		},
+	})
+	cache := NewCacheWithOptions(client, Options{FreshTTL: 20 * time.Millisecond})
+
+	cache.Get("example.com")
+	time.Sleep(30 * time.Millisecond)
+
+	// Failed refresh keeps the stale value, the next caller retries
+	for _, want := range []string{"response1", "response1", "response2"} {
+		if resp, err := cache.Get("example.com"); err != nil || resp != want {
+			t.Errorf("Expected %s, got %q, %v", want, resp, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHardTTL(t *testing.T) {
+	client := newMockClient(map[string][]response{
+		"example.com": {
+			{body: "response1"},
+			{body: "response2", delay: 50 * time.Millisecond},
+		},
+	})
+	cache := NewCacheWithOptions(client, Options{FreshTTL: 10 * time.Millisecond, HardTTL: 30 * time.Millisecond})
+
+	cache.Get("example.com")
+	time.Sleep(40 * time.Millisecond)
+
+	// Expired value is not returned, the caller waits for a new one
+	start := time.Now()
+	if resp, err := cache.Get("example.com"); err != nil || resp != "response2" {
+		t.Errorf("Expected response2, got %q, %v", resp, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected to wait for the fetch, took %v", elapsed)
+	}
+}
+
+func TestStaleRefreshBounded(t *testing.T) {
+	client := newMockClient(map[string][]response{
+		"a.com": {{body: "1"}, {body: "12345"}},
+		"b.com": {{body: "1"}},
+	})
+	cache := NewCacheWithOptions(client, Options{FreshTTL: 10 * time.Millisecond, MaxBytes: 5})
+
+	cache.Get("b.com")
+	cache.Get("a.com")
+	time.Sleep(20 * time.Millisecond)
+	cache.Get("a.com")
+	time.Sleep(20 * time.Millisecond)
+
+	// Refreshed value is larger, so the least recently used b.com is evicted
+	if resp, err := cache.Get("a.com"); err != nil || resp != "12345" {
+		t.Errorf("Expected 12345, got %q, %v", resp, err)
+	}
+	if _, err := cache.Get("b.com"); err != ErrNoResponse {
+		t.Errorf("Expected b.com to be evicted, got %v", err)
+	}
+}
+
+func TestStaleRefreshTooLarge(t *testing.T) {
+	ten := strings.Repeat("x", 10)
+	client := newMockClient(map[string][]response{
+		"a.com": {{body: ten}},
+		"b.com": {{body: ten}},
+		"c.com": {{body: ten}, {body: strings.Repeat("x", 150)}, {body: ten}},
+	})
+	cache := NewCacheWithOptions(client, Options{FreshTTL: 10 * time.Millisecond, MaxBytes: 100})
+
+	cache.Get("a.com")
+	cache.Get("b.com")
+	cache.Get("c.com")
+	time.Sleep(20 * time.Millisecond)
+	cache.Get("c.com")
+	time.Sleep(20 * time.Millisecond)
+
+	// Refreshed value doesn't fit at all, so only c.com is dropped
+	for _, address := range []string{"a.com", "b.com"} {
+		if resp, err := cache.Get(address); err != nil || resp != ten {
+			t.Errorf("Expected %s to stay, got %q, %v", address, resp, err)
+		}
+	}
+	if resp, err := cache.Get("c.com"); err != nil || resp != ten {
+		t.Errorf("Expected c.com to be fetched again, got %q, %v", resp, err)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/blindlobstar/go-interview-problems
 
 go 1.24.1
 
-require golang.org/x/tour v0.1.0
+require (
+	golang.org/x/net v0.44.0
+	golang.org/x/tour v0.1.0
+)